@@ -7,7 +7,16 @@ CREATE TABLE `users` (
   `phone_number` CHAR(11),
   `gender` TINYINT,
   `role_id` INT NOT NULL DEFAULT 1,
-  `created_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP)
+  `verified_at` TIMESTAMP NULL,
+  `totp_secret` VARCHAR(64),
+  `totp_enabled_at` TIMESTAMP NULL,
+  `totp_last_step` BIGINT,
+  `avatar_key` VARCHAR(255),
+  `status` VARCHAR(20) NOT NULL DEFAULT 'active',
+  `status_reason` VARCHAR(255),
+  `status_changed_at` TIMESTAMP NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP),
+  `deleted_at` TIMESTAMP NULL
 );
 
 CREATE TABLE `roles` (
@@ -15,20 +24,61 @@ CREATE TABLE `roles` (
   `role_name` VARCHAR(255) NOT NULL
 );
 
+CREATE TABLE `permissions` (
+  `permission_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `permission_name` VARCHAR(255) UNIQUE NOT NULL,
+  `description` VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE `role_permissions` (
+  `role_id` INT NOT NULL,
+  `permission_id` INT NOT NULL,
+  PRIMARY KEY (`role_id`, `permission_id`)
+);
+
+CREATE TABLE `api_keys` (
+  `key_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `name` VARCHAR(255) NOT NULL,
+  `prefix` CHAR(8) NOT NULL,
+  `key_hash` CHAR(64) UNIQUE NOT NULL,
+  `scopes` VARCHAR(1024) NOT NULL,
+  `created_by` INT NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP),
+  `last_used_at` TIMESTAMP NULL,
+  `revoked_at` TIMESTAMP NULL
+);
+
 CREATE TABLE `addresses` (
   `address_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
   `user_id` INT NOT NULL,
   `name` VARCHAR(255) NOT NULL,
-  `address` VARCHAR(255) NOT NULL,
+  `recipient_name` VARCHAR(100) NOT NULL,
+  `phone_number` VARCHAR(11) NOT NULL,
+  `street` VARCHAR(255) NOT NULL,
+  `ward` VARCHAR(100) NOT NULL,
+  `district` VARCHAR(100) NOT NULL,
+  `province` VARCHAR(100) NOT NULL,
+  `postal_code` VARCHAR(6) NOT NULL DEFAULT '',
   `is_default` TINYINT NOT NULL
 );
 
 CREATE TABLE `orders` (
   `order_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
   `user_id` INT NOT NULL,
+  `subtotal_price` DECIMAL(12,0) NOT NULL,
+  `shipping_method` VARCHAR(20) NOT NULL DEFAULT '',
+  `shipping_fee` DECIMAL(12,0) NOT NULL DEFAULT 0,
   `total_price` DECIMAL(12,0) NOT NULL,
   `payment_method` VARCHAR(255) NOT NULL,
+  `address_id` INT,
   `address` VARCHAR(255) NOT NULL,
+  `shipping_recipient_name` VARCHAR(100) NOT NULL DEFAULT '',
+  `shipping_phone_number` VARCHAR(11) NOT NULL DEFAULT '',
+  `shipping_street` VARCHAR(255) NOT NULL DEFAULT '',
+  `shipping_ward` VARCHAR(100) NOT NULL DEFAULT '',
+  `shipping_district` VARCHAR(100) NOT NULL DEFAULT '',
+  `shipping_province` VARCHAR(100) NOT NULL DEFAULT '',
+  `shipping_postal_code` VARCHAR(6) NOT NULL DEFAULT '',
   `status` VARCHAR(255) NOT NULL,
   `created_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP)
 );
@@ -48,7 +98,8 @@ CREATE TABLE `products` (
   `product_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
   `product_name` VARCHAR(255) NOT NULL,
   `price` DECIMAL(12,0) NOT NULL,
-  `total_quantity` INT NOT NULL DEFAULT 0
+  `total_quantity` INT NOT NULL DEFAULT 0,
+  `weight_grams` INT NOT NULL DEFAULT 0
 );
 
 CREATE TABLE `product_images` (
@@ -58,6 +109,34 @@ CREATE TABLE `product_images` (
   `is_thumbnail` TINYINT NOT NULL
 );
 
+CREATE TABLE `categories` (
+  `category_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `parent_id` INT NULL,
+  `name` VARCHAR(255) NOT NULL,
+  `slug` VARCHAR(255) UNIQUE NOT NULL,
+  `position` INT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE `product_categories` (
+  `product_id` INT NOT NULL,
+  `category_id` INT NOT NULL,
+  PRIMARY KEY (`product_id`, `category_id`)
+);
+
+CREATE TABLE `collections` (
+  `collection_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `name` VARCHAR(255) NOT NULL,
+  `slug` VARCHAR(255) UNIQUE NOT NULL,
+  `description` VARCHAR(1000) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE `collection_products` (
+  `collection_id` INT NOT NULL,
+  `product_id` INT NOT NULL,
+  `position` INT NOT NULL,
+  PRIMARY KEY (`collection_id`, `product_id`)
+);
+
 CREATE TABLE `cart_products` (
   `id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
   `user_id` INT NOT NULL,
@@ -74,6 +153,107 @@ CREATE TABLE `sizes` (
   `quantity` INT NOT NULL
 );
 
+CREATE TABLE `sessions` (
+  `session_id` CHAR(32) PRIMARY KEY NOT NULL,
+  `user_id` INT NOT NULL,
+  `mfa_verified` TINYINT NOT NULL DEFAULT 0,
+  `ip_address` VARCHAR(45) NOT NULL DEFAULT '',
+  `user_agent` VARCHAR(255) NOT NULL DEFAULT '',
+  `created_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP),
+  `last_used_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP),
+  `revoked_at` TIMESTAMP NULL
+);
+
+CREATE TABLE `login_history` (
+  `login_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `user_id` INT NOT NULL,
+  `session_id` CHAR(32) NOT NULL,
+  `ip_address` VARCHAR(45) NOT NULL DEFAULT '',
+  `user_agent` VARCHAR(255) NOT NULL DEFAULT '',
+  `new_device` TINYINT NOT NULL DEFAULT 0,
+  `created_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP)
+);
+
+CREATE TABLE `refresh_tokens` (
+  `token_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `session_id` CHAR(32) NOT NULL,
+  `token_hash` CHAR(64) UNIQUE NOT NULL,
+  `expires_at` TIMESTAMP NOT NULL,
+  `used_at` TIMESTAMP NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP)
+);
+
+CREATE TABLE `revoked_tokens` (
+  `jti` CHAR(32) PRIMARY KEY NOT NULL,
+  `expires_at` TIMESTAMP NOT NULL
+);
+
+CREATE TABLE `password_resets` (
+  `reset_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `user_id` INT NOT NULL,
+  `token_hash` CHAR(64) UNIQUE NOT NULL,
+  `expires_at` TIMESTAMP NOT NULL,
+  `used_at` TIMESTAMP NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP)
+);
+
+CREATE TABLE `email_changes` (
+  `change_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `user_id` INT NOT NULL,
+  `new_email` VARCHAR(255) NOT NULL,
+  `token_hash` CHAR(64) UNIQUE NOT NULL,
+  `expires_at` TIMESTAMP NOT NULL,
+  `used_at` TIMESTAMP NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP)
+);
+
+CREATE TABLE `recovery_codes` (
+  `code_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `user_id` INT NOT NULL,
+  `code_hash` CHAR(64) NOT NULL,
+  `used_at` TIMESTAMP NULL
+);
+
+CREATE TABLE `audit_log` (
+  `audit_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `actor_id` INT NULL,
+  `api_key_id` INT NULL,
+  `action` VARCHAR(100) NOT NULL,
+  `entity_type` VARCHAR(50) NOT NULL,
+  `entity_id` VARCHAR(64) NOT NULL,
+  `changes` JSON NULL,
+  `details` JSON NULL,
+  `ip_address` VARCHAR(45) NOT NULL DEFAULT '',
+  `created_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP),
+  INDEX (`actor_id`, `created_at`),
+  INDEX (`entity_type`, `entity_id`, `created_at`),
+  INDEX (`created_at`)
+);
+
+CREATE TABLE `customer_notes` (
+  `note_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `user_id` INT NOT NULL,
+  `author_id` INT NULL,
+  `body` VARCHAR(2000) NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP)
+);
+
+CREATE TABLE `customer_tags` (
+  `user_id` INT NOT NULL,
+  `tag` VARCHAR(50) NOT NULL,
+  PRIMARY KEY (`user_id`, `tag`)
+);
+
+CREATE TABLE `login_failures` (
+  `lockout_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `scope` VARCHAR(10) NOT NULL,
+  `identifier` VARCHAR(255) NOT NULL,
+  `failed_count` INT NOT NULL DEFAULT 0,
+  `locked_until` TIMESTAMP NULL,
+  `last_failed_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP),
+  UNIQUE (`scope`, `identifier`)
+);
+
 ALTER TABLE `users` ADD FOREIGN KEY (`role_id`) REFERENCES `roles` (`role_id`);
 
 ALTER TABLE `addresses` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`user_id`);
@@ -91,3 +271,79 @@ ALTER TABLE `product_images` ADD FOREIGN KEY (`product_id`) REFERENCES `products
 ALTER TABLE `cart_products` ADD FOREIGN KEY (`size_id`) REFERENCES `sizes` (`size_id`);
 
 ALTER TABLE `sizes` ADD FOREIGN KEY (`product_id`) REFERENCES `products` (`product_id`);
+
+ALTER TABLE `categories` ADD FOREIGN KEY (`parent_id`) REFERENCES `categories` (`category_id`);
+
+ALTER TABLE `product_categories` ADD FOREIGN KEY (`product_id`) REFERENCES `products` (`product_id`);
+
+ALTER TABLE `product_categories` ADD FOREIGN KEY (`category_id`) REFERENCES `categories` (`category_id`);
+
+ALTER TABLE `collection_products` ADD FOREIGN KEY (`collection_id`) REFERENCES `collections` (`collection_id`);
+
+ALTER TABLE `collection_products` ADD FOREIGN KEY (`product_id`) REFERENCES `products` (`product_id`);
+
+ALTER TABLE `sessions` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`user_id`);
+
+ALTER TABLE `refresh_tokens` ADD FOREIGN KEY (`session_id`) REFERENCES `sessions` (`session_id`);
+
+ALTER TABLE `login_history` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`user_id`);
+
+ALTER TABLE `login_history` ADD FOREIGN KEY (`session_id`) REFERENCES `sessions` (`session_id`);
+
+ALTER TABLE `password_resets` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`user_id`);
+
+ALTER TABLE `email_changes` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`user_id`);
+
+ALTER TABLE `recovery_codes` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`user_id`);
+
+ALTER TABLE `role_permissions` ADD FOREIGN KEY (`role_id`) REFERENCES `roles` (`role_id`);
+
+ALTER TABLE `role_permissions` ADD FOREIGN KEY (`permission_id`) REFERENCES `permissions` (`permission_id`);
+
+ALTER TABLE `api_keys` ADD FOREIGN KEY (`created_by`) REFERENCES `users` (`user_id`);
+
+ALTER TABLE `customer_notes` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`user_id`);
+
+ALTER TABLE `customer_notes` ADD FOREIGN KEY (`author_id`) REFERENCES `users` (`user_id`);
+
+ALTER TABLE `customer_tags` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`user_id`);
+
+ALTER TABLE `audit_log` ADD FOREIGN KEY (`actor_id`) REFERENCES `users` (`user_id`);
+
+ALTER TABLE `audit_log` ADD FOREIGN KEY (`api_key_id`) REFERENCES `api_keys` (`key_id`);
+
+INSERT INTO `roles` (`role_id`, `role_name`) VALUES
+  (1, 'Customer'),
+  (2, 'Admin'),
+  (3, 'Warehouse'),
+  (4, 'Support'),
+  (5, 'Catalog Editor');
+
+INSERT INTO `permissions` (`permission_name`, `description`) VALUES
+  ('products:read', 'View products in the back office'),
+  ('products:write', 'Add and update products'),
+  ('products:delete', 'Delete products'),
+  ('orders:read', 'View all orders'),
+  ('orders:update', 'Change order statuses'),
+  ('customers:read', 'View customers and their orders'),
+  ('customers:impersonate', 'View the shop as a customer for support'),
+  ('customers:manage', 'Disable or ban customers and keep notes and tags on them'),
+  ('lockouts:manage', 'View and clear sign-in lockouts'),
+  ('roles:manage', 'Manage roles, permissions and staff assignments'),
+  ('api_keys:manage', 'Create and revoke API keys for integrations'),
+  ('audit_log:read', 'View the audit log of back office changes');
+
+INSERT INTO `role_permissions` (`role_id`, `permission_id`)
+SELECT 2, `permission_id` FROM `permissions`;
+
+INSERT INTO `role_permissions` (`role_id`, `permission_id`)
+SELECT 3, `permission_id` FROM `permissions`
+WHERE `permission_name` IN ('products:read', 'orders:read', 'orders:update');
+
+INSERT INTO `role_permissions` (`role_id`, `permission_id`)
+SELECT 4, `permission_id` FROM `permissions`
+WHERE `permission_name` IN ('orders:read', 'customers:read', 'customers:impersonate', 'customers:manage', 'lockouts:manage');
+
+INSERT INTO `role_permissions` (`role_id`, `permission_id`)
+SELECT 5, `permission_id` FROM `permissions`
+WHERE `permission_name` IN ('products:read', 'products:write', 'products:delete');
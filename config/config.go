@@ -0,0 +1,314 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/quyld17/E-Commerce-Website/services/geo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Config struct {
+	Port       string
+	AppURL     string
+	TOTPIssuer string
+	// TrustedProxies are the reverse proxies allowed to report the client IP
+	// in X-Forwarded-For. With none, the IP of the connection is used as is.
+	TrustedProxies []*net.IPNet
+	CORS           CORS
+	Database       Database
+	JWT            JWT
+	Mailer         Mailer
+	Lockout        Lockout
+	Password       Password
+	Storage        Storage
+	Avatar         Avatar
+	Shipping       Shipping
+}
+
+type CORS struct {
+	AllowOrigins     []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+type Database struct {
+	User     string
+	Password string
+	Host     string
+	Port     string
+	Name     string
+}
+
+type JWT struct {
+	SecretKey   string
+	KeysDir     string
+	ActiveKeyID string
+}
+
+type Mailer struct {
+	Driver       string
+	From         string
+	Dir          string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// Lockout controls when repeated sign-in failures lock an email or an IP.
+// Once a key reaches its threshold every further failure doubles the lockout,
+// starting at BaseLockout and capped at MaxLockout. Counters start over after
+// ResetAfter without failures.
+type Lockout struct {
+	EmailThreshold int
+	IPThreshold    int
+	BaseLockout    time.Duration
+	MaxLockout     time.Duration
+	ResetAfter     time.Duration
+}
+
+// Password is the policy applied whenever a password is chosen. Raising
+// BcryptCost makes existing hashes get upgraded on their owner's next sign-in.
+type Password struct {
+	MinLength  int
+	BcryptCost int
+}
+
+// Storage is where uploaded files are kept. The local driver writes them
+// under Dir and serves them from BaseURL.
+type Storage struct {
+	Driver  string
+	Dir     string
+	BaseURL string
+}
+
+type Avatar struct {
+	MaxBytes int
+}
+
+// Shipping describes where parcels leave from. OriginProvince is the province
+// code of the warehouse, which decides the zone of every destination.
+// DefaultItemWeight, in grams, is used for products without a weight.
+type Shipping struct {
+	OriginProvince    int
+	DefaultItemWeight int
+}
+
+// Load reads the configuration once at startup. Flags win over environment
+// variables, which win over the optional env file, which wins over defaults.
+// Every problem is reported at once so a bad deployment fails with one clear
+// message instead of on the first request that needs the missing value.
+func Load(args []string) (*Config, error) {
+	flags := flag.NewFlagSet("ecw-server", flag.ContinueOnError)
+	envFile := flags.String("env-file", ".env", "optional file with KEY=VALUE settings")
+	port := flags.String("port", "", "port to listen on, overrides PORT")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := godotenv.Load(*envFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("config: reading %s: %w", *envFile, err)
+	}
+
+	l := loader{}
+	cfg := &Config{
+		Port:           l.str("PORT", "8080"),
+		AppURL:         l.required("APP_URL"),
+		TOTPIssuer:     l.str("TOTP_ISSUER", "ECW"),
+		TrustedProxies: l.networks("TRUSTED_PROXIES"),
+		CORS: CORS{
+			AllowOrigins:     l.list("CORS_ALLOW_ORIGINS", []string{"*"}),
+			AllowCredentials: l.boolean("CORS_ALLOW_CREDENTIALS", true),
+			MaxAge:           l.seconds("CORS_MAX_AGE_SECONDS", 24*time.Hour),
+		},
+		Database: Database{
+			User:     l.required("DB_USER"),
+			Password: l.str("DB_PASSWORD", ""),
+			Host:     l.required("DB_HOST"),
+			Port:     l.str("DB_PORT", "3306"),
+			Name:     l.required("DB_NAME"),
+		},
+		JWT: JWT{
+			SecretKey:   l.str("JWT_SECRET_KEY", ""),
+			KeysDir:     l.str("JWT_KEYS_DIR", ""),
+			ActiveKeyID: l.str("JWT_ACTIVE_KEY_ID", ""),
+		},
+		Mailer: Mailer{
+			Driver:       l.str("MAILER_DRIVER", "stdout"),
+			From:         l.str("MAILER_FROM", ""),
+			Dir:          l.str("MAILER_DIR", "mail"),
+			SMTPHost:     l.str("SMTP_HOST", ""),
+			SMTPPort:     l.str("SMTP_PORT", "587"),
+			SMTPUsername: l.str("SMTP_USERNAME", ""),
+			SMTPPassword: l.str("SMTP_PASSWORD", ""),
+		},
+		Lockout: Lockout{
+			EmailThreshold: l.integer("LOGIN_LOCKOUT_EMAIL_THRESHOLD", 5),
+			IPThreshold:    l.integer("LOGIN_LOCKOUT_IP_THRESHOLD", 20),
+			BaseLockout:    l.seconds("LOGIN_LOCKOUT_BASE_SECONDS", 30*time.Second),
+			MaxLockout:     l.seconds("LOGIN_LOCKOUT_MAX_SECONDS", time.Hour),
+			ResetAfter:     l.seconds("LOGIN_LOCKOUT_RESET_SECONDS", 24*time.Hour),
+		},
+		Password: Password{
+			MinLength:  l.integer("PASSWORD_MIN_LENGTH", 8),
+			BcryptCost: l.integer("BCRYPT_COST", bcrypt.DefaultCost),
+		},
+		Storage: Storage{
+			Driver:  l.str("STORAGE_DRIVER", "local"),
+			Dir:     l.str("STORAGE_DIR", "uploads"),
+			BaseURL: strings.TrimSuffix(l.str("STORAGE_BASE_URL", "/uploads"), "/"),
+		},
+		Avatar: Avatar{
+			MaxBytes: l.integer("AVATAR_MAX_BYTES", 5<<20),
+		},
+		Shipping: Shipping{
+			OriginProvince:    l.integer("SHIPPING_ORIGIN_PROVINCE", 79),
+			DefaultItemWeight: l.integer("SHIPPING_DEFAULT_ITEM_WEIGHT_GRAMS", 500),
+		},
+	}
+
+	if *port != "" {
+		cfg.Port = *port
+	}
+
+	if cfg.JWT.ActiveKeyID == "" && cfg.JWT.SecretKey == "" {
+		l.fail("JWT_SECRET_KEY or JWT_ACTIVE_KEY_ID must be set")
+	}
+	if cfg.JWT.ActiveKeyID != "" && cfg.JWT.KeysDir == "" {
+		l.fail("JWT_KEYS_DIR must be set when JWT_ACTIVE_KEY_ID is set")
+	}
+
+	if cfg.Password.BcryptCost < bcrypt.MinCost || cfg.Password.BcryptCost > bcrypt.MaxCost {
+		l.fail(fmt.Sprintf("BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost))
+	}
+	if cfg.Password.MinLength > 72 {
+		l.fail("PASSWORD_MIN_LENGTH can not be above 72, the longest password bcrypt accepts")
+	}
+
+	switch cfg.Mailer.Driver {
+	case "smtp":
+		if cfg.Mailer.SMTPHost == "" {
+			l.fail("SMTP_HOST must be set when MAILER_DRIVER is smtp")
+		}
+		if cfg.Mailer.From == "" {
+			l.fail("MAILER_FROM must be set when MAILER_DRIVER is smtp")
+		}
+	case "file", "stdout":
+	default:
+		l.fail(fmt.Sprintf("MAILER_DRIVER must be smtp, file or stdout, got %q", cfg.Mailer.Driver))
+	}
+
+	if cfg.Storage.Driver != "local" {
+		l.fail(fmt.Sprintf("STORAGE_DRIVER must be local, got %q", cfg.Storage.Driver))
+	}
+
+	if _, ok := geo.ProvinceByCode(cfg.Shipping.OriginProvince); !ok {
+		l.fail(fmt.Sprintf("SHIPPING_ORIGIN_PROVINCE must be a province code, got %d", cfg.Shipping.OriginProvince))
+	}
+
+	if len(l.problems) > 0 {
+		return nil, fmt.Errorf("config: %s", strings.Join(l.problems, "; "))
+	}
+	return cfg, nil
+}
+
+type loader struct {
+	problems []string
+}
+
+func (l *loader) fail(problem string) {
+	l.problems = append(l.problems, problem)
+}
+
+func (l *loader) str(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func (l *loader) required(key string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		l.fail(key + " is required")
+	}
+	return value
+}
+
+func (l *loader) list(key string, fallback []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// networks reads a list of CIDR ranges. A bare IP stands for itself.
+func (l *loader) networks(key string) []*net.IPNet {
+	networks := []*net.IPNet{}
+	for _, item := range l.list(key, nil) {
+		cidr := item
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			l.fail(fmt.Sprintf("%s must be a list of IPs or CIDR ranges, got %q", key, item))
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func (l *loader) boolean(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		l.fail(fmt.Sprintf("%s must be true or false, got %q", key, value))
+		return fallback
+	}
+	return parsed
+}
+
+func (l *loader) integer(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		l.fail(fmt.Sprintf("%s must be a positive integer, got %q", key, value))
+		return fallback
+	}
+	return parsed
+}
+
+func (l *loader) seconds(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	return time.Duration(l.integer(key, 0)) * time.Second
+}
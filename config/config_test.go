@@ -0,0 +1,276 @@
+package config
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// keys are unset before every test so the environment of the machine
+// running the tests can not leak in.
+var keys = []string{
+	"PORT", "APP_URL", "TOTP_ISSUER", "TRUSTED_PROXIES",
+	"CORS_ALLOW_ORIGINS", "CORS_ALLOW_CREDENTIALS", "CORS_MAX_AGE_SECONDS",
+	"DB_USER", "DB_PASSWORD", "DB_HOST", "DB_PORT", "DB_NAME",
+	"JWT_SECRET_KEY", "JWT_KEYS_DIR", "JWT_ACTIVE_KEY_ID",
+	"MAILER_DRIVER", "MAILER_FROM", "MAILER_DIR", "SMTP_HOST", "SMTP_PORT", "SMTP_USERNAME", "SMTP_PASSWORD",
+	"LOGIN_LOCKOUT_EMAIL_THRESHOLD", "LOGIN_LOCKOUT_IP_THRESHOLD",
+	"LOGIN_LOCKOUT_BASE_SECONDS", "LOGIN_LOCKOUT_MAX_SECONDS", "LOGIN_LOCKOUT_RESET_SECONDS",
+	"PASSWORD_MIN_LENGTH", "BCRYPT_COST",
+	"STORAGE_DRIVER", "STORAGE_DIR", "STORAGE_BASE_URL",
+	"AVATAR_MAX_BYTES",
+	"SHIPPING_ORIGIN_PROVINCE", "SHIPPING_DEFAULT_ITEM_WEIGHT_GRAMS",
+}
+
+var required = map[string]string{
+	"APP_URL":        "https://shop.example.com",
+	"DB_USER":        "ecw",
+	"DB_HOST":        "localhost",
+	"DB_NAME":        "ecw",
+	"JWT_SECRET_KEY": "secret",
+}
+
+// load runs Load with a clean environment holding the required settings
+// plus env, where an empty value removes a required one. It never reads a
+// real .env file.
+func load(t *testing.T, env map[string]string, args ...string) (*Config, error) {
+	t.Helper()
+	for _, key := range keys {
+		unsetenv(t, key)
+	}
+	for key, value := range required {
+		t.Setenv(key, value)
+	}
+	for key, value := range env {
+		if value == "" {
+			unsetenv(t, key)
+			continue
+		}
+		t.Setenv(key, value)
+	}
+	if !containsFlag(args, "-env-file") {
+		args = append([]string{"-env-file", filepath.Join(t.TempDir(), "missing.env")}, args...)
+	}
+	return Load(args)
+}
+
+// unsetenv removes key for the rest of the test. Setting it to "" is not
+// enough, since godotenv never overrides a variable that exists.
+func unsetenv(t *testing.T, key string) {
+	t.Helper()
+	t.Setenv(key, "")
+	if err := os.Unsetenv(key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func containsFlag(args []string, flag string) bool {
+	for _, arg := range args {
+		if arg == flag || strings.HasPrefix(arg, flag+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := load(t, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checks := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"Port", cfg.Port, "8080"},
+		{"TOTPIssuer", cfg.TOTPIssuer, "ECW"},
+		{"TrustedProxies", len(cfg.TrustedProxies), 0},
+		{"CORS.AllowOrigins", cfg.CORS.AllowOrigins, []string{"*"}},
+		{"CORS.AllowCredentials", cfg.CORS.AllowCredentials, true},
+		{"CORS.MaxAge", cfg.CORS.MaxAge, 24 * time.Hour},
+		{"Database.Port", cfg.Database.Port, "3306"},
+		{"Mailer.Driver", cfg.Mailer.Driver, "stdout"},
+		{"Lockout.EmailThreshold", cfg.Lockout.EmailThreshold, 5},
+		{"Lockout.IPThreshold", cfg.Lockout.IPThreshold, 20},
+		{"Lockout.BaseLockout", cfg.Lockout.BaseLockout, 30 * time.Second},
+		{"Lockout.MaxLockout", cfg.Lockout.MaxLockout, time.Hour},
+		{"Lockout.ResetAfter", cfg.Lockout.ResetAfter, 24 * time.Hour},
+		{"Password.MinLength", cfg.Password.MinLength, 8},
+		{"Password.BcryptCost", cfg.Password.BcryptCost, bcrypt.DefaultCost},
+		{"Storage.BaseURL", cfg.Storage.BaseURL, "/uploads"},
+		{"Avatar.MaxBytes", cfg.Avatar.MaxBytes, 5 << 20},
+		{"Shipping.OriginProvince", cfg.Shipping.OriginProvince, 79},
+		{"Shipping.DefaultItemWeight", cfg.Shipping.DefaultItemWeight, 500},
+	}
+	for _, check := range checks {
+		if !reflect.DeepEqual(check.got, check.want) {
+			t.Errorf("%s = %v; want %v", check.name, check.got, check.want)
+		}
+	}
+}
+
+func TestLoadParsesValues(t *testing.T) {
+	cfg, err := load(t, map[string]string{
+		"PORT":                       "9000",
+		"TRUSTED_PROXIES":            "10.0.0.0/8, 192.168.1.10 ,2001:db8::1",
+		"CORS_ALLOW_ORIGINS":         " https://a.example , https://b.example ,",
+		"CORS_ALLOW_CREDENTIALS":     "false",
+		"LOGIN_LOCKOUT_BASE_SECONDS": "45",
+		"STORAGE_BASE_URL":           "https://cdn.example.com/",
+		"SHIPPING_ORIGIN_PROVINCE":   "1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Port != "9000" {
+		t.Errorf("Port = %q; want 9000", cfg.Port)
+	}
+	proxies := []string{}
+	for _, network := range cfg.TrustedProxies {
+		proxies = append(proxies, network.String())
+	}
+	if want := []string{"10.0.0.0/8", "192.168.1.10/32", "2001:db8::1/128"}; !reflect.DeepEqual(proxies, want) {
+		t.Errorf("TrustedProxies = %v; want %v", proxies, want)
+	}
+	if !cfg.TrustedProxies[0].Contains(net.ParseIP("10.1.2.3")) {
+		t.Errorf("10.0.0.0/8 does not contain 10.1.2.3")
+	}
+	if want := []string{"https://a.example", "https://b.example"}; !reflect.DeepEqual(cfg.CORS.AllowOrigins, want) {
+		t.Errorf("CORS.AllowOrigins = %v; want %v", cfg.CORS.AllowOrigins, want)
+	}
+	if cfg.CORS.AllowCredentials {
+		t.Errorf("CORS.AllowCredentials = true; want false")
+	}
+	if cfg.Lockout.BaseLockout != 45*time.Second {
+		t.Errorf("Lockout.BaseLockout = %v; want 45s", cfg.Lockout.BaseLockout)
+	}
+	if cfg.Storage.BaseURL != "https://cdn.example.com" {
+		t.Errorf("Storage.BaseURL = %q; want the trailing slash trimmed", cfg.Storage.BaseURL)
+	}
+	if cfg.Shipping.OriginProvince != 1 {
+		t.Errorf("Shipping.OriginProvince = %d; want 1", cfg.Shipping.OriginProvince)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), "test.env")
+	err := os.WriteFile(envFile, []byte("PORT=7000\nTOTP_ISSUER=From file\nDB_PORT=3307\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := load(t, map[string]string{"PORT": "8000", "TOTP_ISSUER": "From env"}, "-env-file", envFile, "-port", "9000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "9000" {
+		t.Errorf("Port = %q; want the flag to win", cfg.Port)
+	}
+	if cfg.TOTPIssuer != "From env" {
+		t.Errorf("TOTPIssuer = %q; want the environment to win over the file", cfg.TOTPIssuer)
+	}
+	if cfg.Database.Port != "3307" {
+		t.Errorf("Database.Port = %q; want the file to win over the default", cfg.Database.Port)
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "every missing setting is reported at once",
+			env:  map[string]string{"APP_URL": "", "DB_USER": "", "DB_HOST": "", "DB_NAME": ""},
+			want: []string{"APP_URL is required", "DB_USER is required", "DB_HOST is required", "DB_NAME is required"},
+		},
+		{
+			name: "no signing key",
+			env:  map[string]string{"JWT_SECRET_KEY": ""},
+			want: []string{"JWT_SECRET_KEY or JWT_ACTIVE_KEY_ID must be set"},
+		},
+		{
+			name: "active key without a keys directory",
+			env:  map[string]string{"JWT_ACTIVE_KEY_ID": "2024-01"},
+			want: []string{"JWT_KEYS_DIR must be set"},
+		},
+		{
+			name: "integers must be positive",
+			env:  map[string]string{"LOGIN_LOCKOUT_EMAIL_THRESHOLD": "0", "AVATAR_MAX_BYTES": "lots"},
+			want: []string{`LOGIN_LOCKOUT_EMAIL_THRESHOLD must be a positive integer, got "0"`, `AVATAR_MAX_BYTES must be a positive integer, got "lots"`},
+		},
+		{
+			name: "seconds must be positive",
+			env:  map[string]string{"LOGIN_LOCKOUT_MAX_SECONDS": "-1"},
+			want: []string{"LOGIN_LOCKOUT_MAX_SECONDS must be a positive integer"},
+		},
+		{
+			name: "booleans",
+			env:  map[string]string{"CORS_ALLOW_CREDENTIALS": "maybe"},
+			want: []string{"CORS_ALLOW_CREDENTIALS must be true or false"},
+		},
+		{
+			name: "bcrypt cost out of range",
+			env:  map[string]string{"BCRYPT_COST": "40"},
+			want: []string{"BCRYPT_COST must be between"},
+		},
+		{
+			name: "minimum password length above what bcrypt accepts",
+			env:  map[string]string{"PASSWORD_MIN_LENGTH": "73"},
+			want: []string{"PASSWORD_MIN_LENGTH can not be above 72"},
+		},
+		{
+			name: "smtp without host or sender",
+			env:  map[string]string{"MAILER_DRIVER": "smtp"},
+			want: []string{"SMTP_HOST must be set", "MAILER_FROM must be set"},
+		},
+		{
+			name: "unknown mailer driver",
+			env:  map[string]string{"MAILER_DRIVER": "pigeon"},
+			want: []string{`MAILER_DRIVER must be smtp, file or stdout, got "pigeon"`},
+		},
+		{
+			name: "unknown storage driver",
+			env:  map[string]string{"STORAGE_DRIVER": "s3"},
+			want: []string{`STORAGE_DRIVER must be local, got "s3"`},
+		},
+		{
+			name: "origin that is not a province",
+			env:  map[string]string{"SHIPPING_ORIGIN_PROVINCE": "99"},
+			want: []string{"SHIPPING_ORIGIN_PROVINCE must be a province code, got 99"},
+		},
+		{
+			name: "trusted proxy that is not an IP or range",
+			env:  map[string]string{"TRUSTED_PROXIES": "10.0.0.0/8,proxy.internal"},
+			want: []string{`TRUSTED_PROXIES must be a list of IPs or CIDR ranges, got "proxy.internal"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := load(t, tt.env)
+			if err == nil {
+				t.Fatalf("Load = %+v; want an error", cfg)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadRejectsUnknownFlags(t *testing.T) {
+	if _, err := load(t, nil, "-verbose"); err == nil {
+		t.Fatal("Load accepted an unknown flag")
+	}
+}
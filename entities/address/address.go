@@ -2,26 +2,128 @@ package addresses
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/labstack/echo/v4"
+	"github.com/quyld17/E-Commerce-Website/services/geo"
 )
 
 type Address struct {
-	AddressID int    `json: "address_id"`
-	Name      string `json: "name"`
-	Address   string `json: "address"`
-	IsDefault int    `json: "is_default"`
+	AddressID     int    `json:"address_id"`
+	Name          string `json:"name"`
+	RecipientName string `json:"recipient_name"`
+	PhoneNumber   string `json:"phone_number"`
+	Street        string `json:"street"`
+	Ward          string `json:"ward"`
+	District      string `json:"district"`
+	Province      string `json:"province"`
+	PostalCode    string `json:"postal_code"`
+	Address       string `json:"address"`
+	IsDefault     int    `json:"is_default"`
 }
 
-func Add(userID int, name, address string, c echo.Context, db *sql.DB) error {
+var ErrNotFound = errors.New("Address not found")
+
+var (
+	phonePattern      = regexp.MustCompile(`^0[0-9]{9,10}$`)
+	postalCodePattern = regexp.MustCompile(`^[0-9]{5,6}$`)
+)
+
+// Validate trims the fields of an address, normalizes the phone number to
+// its local 0xxx form and fills in the one-line Address used on labels and
+// older clients. The Name label is only checked when saving to the address
+// book, since order snapshots do not carry it.
+func Validate(address Address) (Address, error) {
+	address.RecipientName = strings.TrimSpace(address.RecipientName)
+	address.Street = strings.TrimSpace(address.Street)
+	address.Ward = strings.TrimSpace(address.Ward)
+	address.District = strings.TrimSpace(address.District)
+	address.Province = strings.TrimSpace(address.Province)
+	address.PostalCode = strings.TrimSpace(address.PostalCode)
+	address.PhoneNumber = normalizePhone(address.PhoneNumber)
+
+	switch {
+	case address.RecipientName == "" || len(address.RecipientName) > 100:
+		return Address{}, fmt.Errorf("Invalid recipient name! Please try again")
+	case !phonePattern.MatchString(address.PhoneNumber):
+		return Address{}, fmt.Errorf("Invalid phone number! Please try again")
+	case address.Street == "" || len(address.Street) > 255:
+		return Address{}, fmt.Errorf("Invalid street address! Please try again")
+	case address.Ward == "" || len(address.Ward) > 100:
+		return Address{}, fmt.Errorf("Invalid ward! Please try again")
+	case address.District == "" || len(address.District) > 100:
+		return Address{}, fmt.Errorf("Invalid district! Please try again")
+	case address.Province == "" || len(address.Province) > 100:
+		return Address{}, fmt.Errorf("Invalid province! Please try again")
+	case address.PostalCode != "" && !postalCodePattern.MatchString(address.PostalCode):
+		return Address{}, fmt.Errorf("Invalid postal code! Please try again")
+	}
+
+	address.Address = Format(address)
+	return address, nil
+}
+
+// Format joins the structured fields into a single line. Rows migrated from
+// the old free-text column only have a street, which is returned as is.
+func Format(address Address) string {
+	parts := []string{}
+	for _, part := range []string{address.Street, address.Ward, address.District, address.Province, address.PostalCode} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// validateWithName checks an address about to be saved in the address book.
+// On top of Validate it needs a label, and the province, district and ward
+// must exist in the geo dataset. Their official spelling replaces whatever
+// the customer typed.
+func validateWithName(address Address) (Address, error) {
+	address.Name = strings.TrimSpace(address.Name)
+	if address.Name == "" || len(address.Name) > 255 {
+		return Address{}, fmt.Errorf("Invalid address name! Please try again")
+	}
+	address, err := Validate(address)
+	if err != nil {
+		return Address{}, err
+	}
+
+	location, err := geo.Resolve(address.Province, address.District, address.Ward)
+	if err != nil {
+		return Address{}, fmt.Errorf("%s! Please check the spelling", err.Error())
+	}
+	address.Province = location.Province
+	address.District = location.District
+	address.Ward = location.Ward
+	address.Address = Format(address)
+	return address, nil
+}
+
+func normalizePhone(phone string) string {
+	phone = strings.NewReplacer(" ", "", ".", "", "-", "", "(", "", ")", "").Replace(phone)
+	if strings.HasPrefix(phone, "+84") {
+		phone = "0" + strings.TrimPrefix(phone, "+84")
+	}
+	return phone
+}
+
+func Add(userID int, address Address, c echo.Context, db *sql.DB) error {
+	address, err := validateWithName(address)
+	if err != nil {
+		return err
+	}
+
 	row := db.QueryRow(`
 		SELECT address_id 
 		FROM addresses 
 		WHERE 	user_id = ? AND 
 				name = ? 
 		LIMIT 1
-		`, userID, name)
+		`, userID, address.Name)
 	var existingID int
 	if err := row.Scan(&existingID); err == nil {
 		return fmt.Errorf("Address with this name already exists!")
@@ -45,10 +147,17 @@ func Add(userID int, name, address string, c echo.Context, db *sql.DB) error {
 	_, err = db.Exec(`
 		INSERT INTO addresses (	user_id, 
 								name,
-								address,
+								recipient_name,
+								phone_number,
+								street,
+								ward,
+								district,
+								province,
+								postal_code,
 								is_default)
-		VALUES (?, ?, ?, ?);
-		`, userID, name, address, isDefault)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+		`, userID, address.Name, address.RecipientName, address.PhoneNumber, address.Street,
+		address.Ward, address.District, address.Province, address.PostalCode, isDefault)
 	if err != nil {
 		return fmt.Errorf("Error adding address! Please try again")
 	}
@@ -57,10 +166,7 @@ func Add(userID int, name, address string, c echo.Context, db *sql.DB) error {
 
 func Get(userID int, db *sql.DB) ([]Address, error) {
 	rows, err := db.Query(`
-		SELECT 	address_id, 
-				name,
-				address, 
-				is_default
+		SELECT `+columns+`
 		FROM addresses
 		WHERE user_id = ?;
 		`, userID)
@@ -72,8 +178,7 @@ func Get(userID int, db *sql.DB) ([]Address, error) {
 
 	var addresses []Address
 	for rows.Next() {
-		var address Address
-		err := rows.Scan(&address.AddressID, &address.Name, &address.Address, &address.IsDefault)
+		address, err := scan(rows)
 		if err != nil {
 			fmt.Println(err)
 			return nil, fmt.Errorf("Error getting addresses! Please try again")
@@ -89,22 +194,82 @@ func Get(userID int, db *sql.DB) ([]Address, error) {
 
 func GetDefault(userID int, db *sql.DB) (Address, error) {
 	row := db.QueryRow(`
-		SELECT 	address_id, 
-				name, 
-				address, 
-				is_default
+		SELECT `+columns+`
 		FROM addresses
 		WHERE user_id = ? AND is_default = 1;
 		`, userID)
 
-	var address Address
-	if err := row.Scan(&address.AddressID, &address.Name, &address.Address, &address.IsDefault); err != nil {
+	address, err := scan(row)
+	if err == sql.ErrNoRows {
+		return Address{}, ErrNotFound
+	}
+	if err != nil {
 		return Address{}, fmt.Errorf("Error getting default address! Please try again")
 	}
 	return address, nil
 }
 
-func Update(userID int, addressID int, name, address string, c echo.Context, db *sql.DB) error {
+// GetByID returns one of the user's saved addresses. An address owned by
+// someone else is reported as not found.
+func GetByID(userID, addressID int, db *sql.DB) (Address, error) {
+	row := db.QueryRow(`
+		SELECT `+columns+`
+		FROM addresses
+		WHERE user_id = ? AND address_id = ?;
+		`, userID, addressID)
+
+	address, err := scan(row)
+	if err == sql.ErrNoRows {
+		return Address{}, ErrNotFound
+	}
+	if err != nil {
+		return Address{}, fmt.Errorf("Error getting address! Please try again")
+	}
+	return address, nil
+}
+
+const columns = `
+	address_id,
+	name,
+	recipient_name,
+	phone_number,
+	street,
+	ward,
+	district,
+	province,
+	postal_code,
+	is_default`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scan(row scanner) (Address, error) {
+	var address Address
+	err := row.Scan(
+		&address.AddressID,
+		&address.Name,
+		&address.RecipientName,
+		&address.PhoneNumber,
+		&address.Street,
+		&address.Ward,
+		&address.District,
+		&address.Province,
+		&address.PostalCode,
+		&address.IsDefault)
+	if err != nil {
+		return Address{}, err
+	}
+	address.Address = Format(address)
+	return address, nil
+}
+
+func Update(userID int, addressID int, address Address, c echo.Context, db *sql.DB) error {
+	address, err := validateWithName(address)
+	if err != nil {
+		return err
+	}
+
 	row := db.QueryRow(`
 		SELECT address_id 
 		FROM addresses 
@@ -112,18 +277,25 @@ func Update(userID int, addressID int, name, address string, c echo.Context, db
 				name = ? AND 
 				address_id != ? 
 		LIMIT 1
-		`, userID, name, addressID)
+		`, userID, address.Name, addressID)
 	var existingID int
 	if err := row.Scan(&existingID); err == nil {
 		return fmt.Errorf("Another address with this name already exists!")
 	}
 
-	_, err := db.Exec(`
+	_, err = db.Exec(`
 		UPDATE addresses
 		SET name = ?,
-			address = ?
+			recipient_name = ?,
+			phone_number = ?,
+			street = ?,
+			ward = ?,
+			district = ?,
+			province = ?,
+			postal_code = ?
 		WHERE user_id = ? AND address_id = ?;
-		`, name, address, userID, addressID)
+		`, address.Name, address.RecipientName, address.PhoneNumber, address.Street,
+		address.Ward, address.District, address.Province, address.PostalCode, userID, addressID)
 
 	if err != nil {
 		return fmt.Errorf("Error updating address! Please try again")
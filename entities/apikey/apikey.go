@@ -0,0 +1,267 @@
+package apikeys
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quyld17/E-Commerce-Website/entities/audit"
+	users "github.com/quyld17/E-Commerce-Website/entities/user"
+	"github.com/quyld17/E-Commerce-Website/services/token"
+)
+
+const keyPrefix = "ecw_"
+
+var ErrInvalidAPIKey = errors.New("Invalid or revoked API key")
+
+type APIKey struct {
+	KeyID             int       `json:"key_id"`
+	Name              string    `json:"name"`
+	Prefix            string    `json:"prefix"`
+	Scopes            []string  `json:"scopes"`
+	CreatedBy         int       `json:"created_by"`
+	CreatedAt         time.Time `json:"created_at"`
+	CreatedAtDisplay  string    `json:"created_at_display"`
+	LastUsedAtDisplay string    `json:"last_used_at_display"`
+	Revoked           bool      `json:"revoked"`
+}
+
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Create stores a new key and returns it in full. Only the hash and a short
+// prefix are kept, so the full key is shown to the admin exactly once.
+func Create(name string, scopes []string, actor audit.Actor, db *sql.DB) (APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 255 {
+		return APIKey{}, "", fmt.Errorf("Invalid API key name! Please try again")
+	}
+	if len(scopes) == 0 {
+		return APIKey{}, "", fmt.Errorf("An API key needs at least one scope")
+	}
+
+	prefix, err := token.NewID()
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	prefix = prefix[:8]
+	secret, err := token.New(32)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	fullKey := keyPrefix + prefix + "_" + secret
+
+	transaction, err := db.Begin()
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	defer transaction.Rollback()
+
+	result, err := transaction.Exec(`
+		INSERT INTO api_keys (name, prefix, key_hash, scopes, created_by)
+		VALUES (?, ?, ?, ?, ?);
+		`, name, prefix, token.Hash(fullKey), strings.Join(scopes, ","), actor.UserID)
+	if err != nil {
+		return APIKey{}, "", fmt.Errorf("Error creating API key! Please try again")
+	}
+	keyID, err := result.LastInsertId()
+	if err != nil {
+		return APIKey{}, "", err
+	}
+
+	apiKey := APIKey{
+		KeyID:     int(keyID),
+		Name:      name,
+		Prefix:    prefix,
+		Scopes:    scopes,
+		CreatedBy: actor.UserID,
+	}
+
+	err = audit.Record(transaction, actor, audit.Change{
+		Action:     audit.ActionCreate,
+		EntityType: audit.EntityAPIKey,
+		EntityID:   strconv.Itoa(apiKey.KeyID),
+		After: map[string]interface{}{
+			"name":   apiKey.Name,
+			"prefix": apiKey.Prefix,
+			"scopes": apiKey.Scopes,
+		},
+	})
+	if err != nil {
+		return APIKey{}, "", err
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return APIKey{}, "", err
+	}
+	return apiKey, fullKey, nil
+}
+
+// Authenticate resolves a presented key and records when it was last used.
+// A key acts on behalf of the staff member who created it, so it stops
+// working once that account is no longer active, and its scopes are cut
+// down to what their role grants today.
+func Authenticate(fullKey string, db *sql.DB) (APIKey, error) {
+	if !strings.HasPrefix(fullKey, keyPrefix) {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+
+	var apiKey APIKey
+	var scopes string
+	var roleID int
+	err := db.QueryRow(`
+		SELECT k.key_id, k.name, k.prefix, k.scopes, k.created_by, u.role_id
+		FROM api_keys k
+		JOIN users u ON k.created_by = u.user_id
+		WHERE 	k.key_hash = ? AND
+				k.revoked_at IS NULL AND
+				u.status = ? AND
+				u.deleted_at IS NULL;
+		`, token.Hash(fullKey), users.StatusActive).Scan(&apiKey.KeyID, &apiKey.Name, &apiKey.Prefix, &scopes, &apiKey.CreatedBy, &roleID)
+	if err == sql.ErrNoRows {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	granted, err := getRolePermissions(roleID, db)
+	if err != nil {
+		return APIKey{}, err
+	}
+	apiKey.Scopes = []string{}
+	for _, scope := range splitScopes(scopes) {
+		if granted[scope] {
+			apiKey.Scopes = append(apiKey.Scopes, scope)
+		}
+	}
+
+	_, err = db.Exec(`
+		UPDATE api_keys
+		SET last_used_at = CURRENT_TIMESTAMP
+		WHERE key_id = ?;
+		`, apiKey.KeyID)
+	if err != nil {
+		return APIKey{}, err
+	}
+	return apiKey, nil
+}
+
+func getRolePermissions(roleID int, db *sql.DB) (map[string]bool, error) {
+	rows, err := db.Query(`
+		SELECT p.permission_name
+		FROM role_permissions rp
+		JOIN permissions p ON rp.permission_id = p.permission_id
+		WHERE rp.role_id = ?;
+		`, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	granted := map[string]bool{}
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		granted[permission] = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return granted, nil
+}
+
+func GetAll(db *sql.DB) ([]APIKey, error) {
+	rows, err := db.Query(`
+		SELECT
+			key_id,
+			name,
+			prefix,
+			scopes,
+			created_by,
+			created_at,
+			last_used_at,
+			revoked_at IS NOT NULL
+		FROM api_keys
+		ORDER BY created_at DESC;
+		`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	apiKeys := []APIKey{}
+	for rows.Next() {
+		var apiKey APIKey
+		var scopes string
+		var lastUsedAt sql.NullTime
+		err := rows.Scan(&apiKey.KeyID, &apiKey.Name, &apiKey.Prefix, &scopes, &apiKey.CreatedBy, &apiKey.CreatedAt, &lastUsedAt, &apiKey.Revoked)
+		if err != nil {
+			return nil, err
+		}
+		apiKey.Scopes = splitScopes(scopes)
+		apiKey.CreatedAtDisplay = apiKey.CreatedAt.Format("2006-01-02 15:04:05")
+		if lastUsedAt.Valid {
+			apiKey.LastUsedAtDisplay = lastUsedAt.Time.Format("2006-01-02 15:04:05")
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return apiKeys, nil
+}
+
+func Revoke(keyID int, actor audit.Actor, db *sql.DB) error {
+	transaction, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer transaction.Rollback()
+
+	result, err := transaction.Exec(`
+		UPDATE api_keys
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE key_id = ? AND revoked_at IS NULL;
+		`, keyID)
+	if err != nil {
+		return fmt.Errorf("Error revoking API key! Please try again")
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("API key not found or already revoked")
+	}
+
+	err = audit.Record(transaction, actor, audit.Change{
+		Action:     audit.ActionUpdate,
+		EntityType: audit.EntityAPIKey,
+		EntityID:   strconv.Itoa(keyID),
+		Before:     map[string]bool{"revoked": false},
+		After:      map[string]bool{"revoked": true},
+	})
+	if err != nil {
+		return err
+	}
+	return transaction.Commit()
+}
+
+func splitScopes(scopes string) []string {
+	if scopes == "" {
+		return []string{}
+	}
+	return strings.Split(scopes, ",")
+}
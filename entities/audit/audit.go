@@ -0,0 +1,233 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	ActionCreate             = "create"
+	ActionUpdate             = "update"
+	ActionDelete             = "delete"
+	ActionImpersonationStart = "impersonation.start"
+)
+
+const (
+	EntityUser       = "user"
+	EntityNote       = "customer_note"
+	EntityProduct    = "product"
+	EntityCategory   = "category"
+	EntityCollection = "collection"
+	EntityOrder      = "order"
+	EntityRole       = "role"
+	EntityAPIKey     = "api_key"
+	EntityLockout    = "lockout"
+)
+
+// Actor is who made a change: a signed-in staff member or an API key.
+type Actor struct {
+	UserID    int
+	APIKeyID  int
+	IPAddress string
+}
+
+// Change describes one write. Before is nil for creations and After is nil
+// for deletions; only fields that differ are kept in the log.
+type Change struct {
+	Action     string
+	EntityType string
+	EntityID   string
+	Before     interface{}
+	After      interface{}
+	Details    interface{}
+}
+
+type Entry struct {
+	AuditID          int             `json:"audit_id"`
+	ActorID          int             `json:"actor_id"`
+	ActorEmail       string          `json:"actor_email"`
+	APIKeyID         int             `json:"api_key_id"`
+	Action           string          `json:"action"`
+	EntityType       string          `json:"entity_type"`
+	EntityID         string          `json:"entity_id"`
+	Changes          json.RawMessage `json:"changes"`
+	Details          json.RawMessage `json:"details"`
+	IPAddress        string          `json:"ip_address"`
+	CreatedAt        time.Time       `json:"created_at"`
+	CreatedAtDisplay string          `json:"created_at_display"`
+}
+
+type Filter struct {
+	ActorID    int
+	EntityType string
+	EntityID   string
+	From       time.Time
+	To         time.Time
+}
+
+// Execer is satisfied by both *sql.DB and *sql.Tx, so an entry can be written
+// in the same transaction as the change it describes.
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func Record(exec Execer, actor Actor, change Change) error {
+	changes, err := diff(change.Before, change.After)
+	if err != nil {
+		return err
+	}
+	details, err := toJSON(change.Details)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec.Exec(`
+		INSERT INTO audit_log (actor_id, api_key_id, action, entity_type, entity_id, changes, details, ip_address)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+		`, nullID(actor.UserID), nullID(actor.APIKeyID), change.Action, change.EntityType, change.EntityID, changes, details, actor.IPAddress)
+	return err
+}
+
+func GetByPage(filter Filter, offset, limit int, db *sql.DB) ([]Entry, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.ActorID != 0 {
+		conditions = append(conditions, "a.actor_id = ?")
+		args = append(args, filter.ActorID)
+	}
+	if filter.EntityType != "" {
+		conditions = append(conditions, "a.entity_type = ?")
+		args = append(args, filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		conditions = append(conditions, "a.entity_id = ?")
+		args = append(args, filter.EntityID)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "a.created_at >= ?")
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "a.created_at < ?")
+		args = append(args, filter.To)
+	}
+
+	query := `
+		SELECT
+			a.audit_id,
+			a.actor_id,
+			u.email,
+			a.api_key_id,
+			a.action,
+			a.entity_type,
+			a.entity_id,
+			a.changes,
+			a.details,
+			a.ip_address,
+			a.created_at
+		FROM audit_log a
+		LEFT JOIN users u ON a.actor_id = u.user_id
+		`
+	if len(conditions) > 0 {
+		query += "WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += `
+		ORDER BY a.created_at DESC, a.audit_id DESC
+		LIMIT ? OFFSET ?;`
+	args = append(args, limit, offset)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var entry Entry
+		var actorID, apiKeyID sql.NullInt64
+		var actorEmail, changes, details sql.NullString
+		err := rows.Scan(&entry.AuditID, &actorID, &actorEmail, &apiKeyID, &entry.Action, &entry.EntityType, &entry.EntityID, &changes, &details, &entry.IPAddress, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entry.ActorID = int(actorID.Int64)
+		entry.ActorEmail = actorEmail.String
+		entry.APIKeyID = int(apiKeyID.Int64)
+		if changes.Valid {
+			entry.Changes = json.RawMessage(changes.String)
+		}
+		if details.Valid {
+			entry.Details = json.RawMessage(details.String)
+		}
+		entry.CreatedAtDisplay = entry.CreatedAt.Format("2006-01-02 15:04:05")
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// diff reduces before and after to the fields that changed and stores them
+// as {"before": {...}, "after": {...}}.
+func diff(before, after interface{}) (sql.NullString, error) {
+	if before == nil && after == nil {
+		return sql.NullString{}, nil
+	}
+	beforeFields, err := toFields(before)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	afterFields, err := toFields(after)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	if beforeFields != nil && afterFields != nil {
+		for field, value := range beforeFields {
+			if other, ok := afterFields[field]; ok && reflect.DeepEqual(value, other) {
+				delete(beforeFields, field)
+				delete(afterFields, field)
+			}
+		}
+	}
+
+	return toJSON(map[string]interface{}{
+		"before": beforeFields,
+		"after":  afterFields,
+	})
+}
+
+func toFields(value interface{}) (map[string]interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func toJSON(value interface{}) (sql.NullString, error) {
+	if value == nil {
+		return sql.NullString{}, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(encoded), Valid: true}, nil
+}
+
+func nullID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
@@ -0,0 +1,78 @@
+package audit
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	type product struct {
+		Name   string `json:"name"`
+		Price  int    `json:"price"`
+		Sizes  []int  `json:"sizes"`
+		Hidden string `json:"-"`
+	}
+
+	tests := []struct {
+		name    string
+		before  interface{}
+		after   interface{}
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "nothing recorded",
+		},
+		{
+			name:  "create keeps every field",
+			after: product{Name: "Shirt", Price: 100, Sizes: []int{1}},
+			want:  `{"after":{"name":"Shirt","price":100,"sizes":[1]},"before":null}`,
+		},
+		{
+			name:   "delete keeps every field",
+			before: map[string]string{"status": "Pending"},
+			want:   `{"after":null,"before":{"status":"Pending"}}`,
+		},
+		{
+			name:   "only changed fields are kept",
+			before: product{Name: "Shirt", Price: 100, Sizes: []int{1, 2}},
+			after:  product{Name: "Shirt", Price: 120, Sizes: []int{1, 2}},
+			want:   `{"after":{"price":120},"before":{"price":100}}`,
+		},
+		{
+			name:   "nested values are compared deeply",
+			before: product{Name: "Shirt", Sizes: []int{1, 2}},
+			after:  product{Name: "Shirt", Sizes: []int{2, 1}},
+			want:   `{"after":{"sizes":[2,1]},"before":{"sizes":[1,2]}}`,
+		},
+		{
+			name:   "fields hidden from JSON are ignored",
+			before: product{Name: "Shirt", Hidden: "a"},
+			after:  product{Name: "Shirt", Hidden: "b"},
+			want:   `{"after":{},"before":{}}`,
+		},
+		{
+			name:   "added and removed fields",
+			before: map[string]interface{}{"status": "active", "reason": "spam"},
+			after:  map[string]interface{}{"status": "banned", "until": "2030-01-01"},
+			want:   `{"after":{"status":"banned","until":"2030-01-01"},"before":{"reason":"spam","status":"active"}}`,
+		},
+		{
+			name:    "values that are not objects are rejected",
+			before:  "Pending",
+			after:   "Shipped",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := diff(tt.before, tt.after)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("diff error = %v; want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Valid != (tt.want != "") || got.String != tt.want {
+				t.Errorf("diff = %q (valid %v); want %q", got.String, got.Valid, tt.want)
+			}
+		})
+	}
+}
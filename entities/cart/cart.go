@@ -20,6 +20,7 @@ func GetProducts(selected string, userID int, c echo.Context, db *sql.DB) ([]pro
 			cp.size_id,
 			p.product_name, 
 			p.price, 
+			p.weight_grams,
 			pi.image_url,
 			s.size_name,
 			s.quantity
@@ -58,6 +59,7 @@ func GetProducts(selected string, userID int, c echo.Context, db *sql.DB) ([]pro
 			&product.SizeID, 
 			&product.ProductName, 
 			&product.Price, 
+			&product.WeightGrams,
 			&product.ImageURL, 
 			&product.SizeName, 
 			&product.SizeQuantity)
@@ -0,0 +1,358 @@
+package categories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/quyld17/E-Commerce-Website/entities/audit"
+	"github.com/quyld17/E-Commerce-Website/services/text"
+)
+
+var ErrNotFound = errors.New("Category not found")
+
+type Category struct {
+	CategoryID int        `json:"category_id"`
+	ParentID   int        `json:"parent_id"`
+	Name       string     `json:"name"`
+	Slug       string     `json:"slug"`
+	Position   int        `json:"position"`
+	Children   []Category `json:"children,omitempty"`
+}
+
+// GetTree returns every category nested under its parent, each level sorted
+// by position and then name.
+func GetTree(db *sql.DB) ([]Category, error) {
+	all, err := getAll(db)
+	if err != nil {
+		return nil, err
+	}
+
+	children := map[int][]Category{}
+	for _, category := range all {
+		children[category.ParentID] = append(children[category.ParentID], category)
+	}
+	var build func(parentID int) []Category
+	build = func(parentID int) []Category {
+		level := children[parentID]
+		for i := range level {
+			level[i].Children = build(level[i].CategoryID)
+		}
+		return level
+	}
+
+	tree := build(0)
+	if tree == nil {
+		tree = []Category{}
+	}
+	return tree, nil
+}
+
+func getAll(db *sql.DB) ([]Category, error) {
+	rows, err := db.Query(`
+		SELECT category_id, parent_id, name, slug, position
+		FROM categories
+		ORDER BY position, name;
+		`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	all := []Category{}
+	for rows.Next() {
+		var category Category
+		var parentID sql.NullInt64
+		if err := rows.Scan(&category.CategoryID, &parentID, &category.Name, &category.Slug, &category.Position); err != nil {
+			return nil, err
+		}
+		category.ParentID = int(parentID.Int64)
+		all = append(all, category)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+func GetBySlug(slug string, db *sql.DB) (Category, error) {
+	var category Category
+	var parentID sql.NullInt64
+	err := db.QueryRow(`
+		SELECT category_id, parent_id, name, slug, position
+		FROM categories
+		WHERE slug = ?;
+		`, slug).Scan(&category.CategoryID, &parentID, &category.Name, &category.Slug, &category.Position)
+	if err == sql.ErrNoRows {
+		return Category{}, ErrNotFound
+	}
+	if err != nil {
+		return Category{}, err
+	}
+	category.ParentID = int(parentID.Int64)
+	return category, nil
+}
+
+// GetSubtreeIDs returns the category and all of its descendants, so a parent
+// category lists the products filed under any of its subcategories.
+func GetSubtreeIDs(categoryID int, db *sql.DB) ([]int, error) {
+	all, err := getAll(db)
+	if err != nil {
+		return nil, err
+	}
+	return subtree(categoryID, all), nil
+}
+
+func subtree(categoryID int, all []Category) []int {
+	ids := []int{categoryID}
+	for i := 0; i < len(ids); i++ {
+		for _, category := range all {
+			if category.ParentID == ids[i] {
+				ids = append(ids, category.CategoryID)
+			}
+		}
+	}
+	return ids
+}
+
+func Create(category Category, actor audit.Actor, db *sql.DB) (Category, error) {
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" || len(category.Name) > 255 {
+		return Category{}, fmt.Errorf("Invalid category name! Please try again")
+	}
+	slug, err := text.Slug(category.Name, category.Slug)
+	if err != nil {
+		return Category{}, err
+	}
+	category.Slug = slug
+
+	transaction, err := db.Begin()
+	if err != nil {
+		return Category{}, err
+	}
+	defer transaction.Rollback()
+
+	if err := checkParent(transaction, 0, category.ParentID); err != nil {
+		return Category{}, err
+	}
+	if err := checkSlug(transaction, 0, category.Slug); err != nil {
+		return Category{}, err
+	}
+
+	result, err := transaction.Exec(`
+		INSERT INTO categories (parent_id, name, slug, position)
+		VALUES (?, ?, ?, ?);
+		`, nullID(category.ParentID), category.Name, category.Slug, category.Position)
+	if err != nil {
+		return Category{}, fmt.Errorf("Error creating category! Please try again")
+	}
+	categoryID, err := result.LastInsertId()
+	if err != nil {
+		return Category{}, err
+	}
+	category.CategoryID = int(categoryID)
+	category.Children = nil
+
+	err = audit.Record(transaction, actor, audit.Change{
+		Action:     audit.ActionCreate,
+		EntityType: audit.EntityCategory,
+		EntityID:   strconv.Itoa(category.CategoryID),
+		After:      category,
+	})
+	if err != nil {
+		return Category{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return Category{}, err
+	}
+	return category, nil
+}
+
+func Update(categoryID int, category Category, actor audit.Actor, db *sql.DB) error {
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" || len(category.Name) > 255 {
+		return fmt.Errorf("Invalid category name! Please try again")
+	}
+	slug, err := text.Slug(category.Name, category.Slug)
+	if err != nil {
+		return err
+	}
+	category.Slug = slug
+	category.CategoryID = categoryID
+	category.Children = nil
+
+	transaction, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer transaction.Rollback()
+
+	before, err := getForUpdate(transaction, categoryID)
+	if err != nil {
+		return err
+	}
+	if err := checkParent(transaction, categoryID, category.ParentID); err != nil {
+		return err
+	}
+	if err := checkSlug(transaction, categoryID, category.Slug); err != nil {
+		return err
+	}
+
+	_, err = transaction.Exec(`
+		UPDATE categories
+		SET parent_id = ?,
+			name = ?,
+			slug = ?,
+			position = ?
+		WHERE category_id = ?;
+		`, nullID(category.ParentID), category.Name, category.Slug, category.Position, categoryID)
+	if err != nil {
+		return fmt.Errorf("Error updating category! Please try again")
+	}
+
+	err = audit.Record(transaction, actor, audit.Change{
+		Action:     audit.ActionUpdate,
+		EntityType: audit.EntityCategory,
+		EntityID:   strconv.Itoa(categoryID),
+		Before:     before,
+		After:      category,
+	})
+	if err != nil {
+		return err
+	}
+	return transaction.Commit()
+}
+
+// Delete removes an empty branch of the tree. Products filed under the
+// category are unassigned but otherwise untouched.
+func Delete(categoryID int, actor audit.Actor, db *sql.DB) error {
+	transaction, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer transaction.Rollback()
+
+	before, err := getForUpdate(transaction, categoryID)
+	if err != nil {
+		return err
+	}
+
+	var hasChildren bool
+	err = transaction.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM categories WHERE parent_id = ?);
+		`, categoryID).Scan(&hasChildren)
+	if err != nil {
+		return err
+	}
+	if hasChildren {
+		return fmt.Errorf("Move or delete the subcategories first")
+	}
+
+	_, err = transaction.Exec(`
+		DELETE FROM product_categories
+		WHERE category_id = ?;
+		`, categoryID)
+	if err != nil {
+		return fmt.Errorf("Error deleting category! Please try again")
+	}
+	_, err = transaction.Exec(`
+		DELETE FROM categories
+		WHERE category_id = ?;
+		`, categoryID)
+	if err != nil {
+		return fmt.Errorf("Error deleting category! Please try again")
+	}
+
+	err = audit.Record(transaction, actor, audit.Change{
+		Action:     audit.ActionDelete,
+		EntityType: audit.EntityCategory,
+		EntityID:   strconv.Itoa(categoryID),
+		Before:     before,
+	})
+	if err != nil {
+		return err
+	}
+	return transaction.Commit()
+}
+
+func getForUpdate(transaction *sql.Tx, categoryID int) (Category, error) {
+	var category Category
+	var parentID sql.NullInt64
+	err := transaction.QueryRow(`
+		SELECT category_id, parent_id, name, slug, position
+		FROM categories
+		WHERE category_id = ?
+		FOR UPDATE;
+		`, categoryID).Scan(&category.CategoryID, &parentID, &category.Name, &category.Slug, &category.Position)
+	if err == sql.ErrNoRows {
+		return Category{}, ErrNotFound
+	}
+	if err != nil {
+		return Category{}, err
+	}
+	category.ParentID = int(parentID.Int64)
+	return category, nil
+}
+
+// checkParent makes sure the parent exists and, when moving an existing
+// category, that it is not the category itself or one of its descendants.
+func checkParent(transaction *sql.Tx, categoryID, parentID int) error {
+	if parentID == 0 {
+		return nil
+	}
+
+	var exists bool
+	err := transaction.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM categories WHERE category_id = ?);
+		`, parentID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("Parent category not found")
+	}
+	if categoryID == 0 {
+		return nil
+	}
+
+	for parentID != 0 {
+		if parentID == categoryID {
+			return fmt.Errorf("A category can not be moved under itself")
+		}
+		var next sql.NullInt64
+		err := transaction.QueryRow(`
+			SELECT parent_id
+			FROM categories
+			WHERE category_id = ?;
+			`, parentID).Scan(&next)
+		if err != nil {
+			return err
+		}
+		parentID = int(next.Int64)
+	}
+	return nil
+}
+
+func checkSlug(transaction *sql.Tx, categoryID int, slug string) error {
+	var taken bool
+	err := transaction.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1
+			FROM categories
+			WHERE slug = ? AND category_id != ?
+		);
+		`, slug, categoryID).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return fmt.Errorf("This slug is already in use")
+	}
+	return nil
+}
+
+func nullID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
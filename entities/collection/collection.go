@@ -0,0 +1,372 @@
+package collections
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/quyld17/E-Commerce-Website/entities/audit"
+	"github.com/quyld17/E-Commerce-Website/services/text"
+)
+
+var ErrNotFound = errors.New("Collection not found")
+
+// Collection is a hand-picked list of products, such as a seasonal campaign.
+// ProductIDs is in the order the products are shown.
+type Collection struct {
+	CollectionID int    `json:"collection_id"`
+	Name         string `json:"name"`
+	Slug         string `json:"slug"`
+	Description  string `json:"description"`
+	ProductIDs   []int  `json:"product_ids"`
+}
+
+func GetAll(db *sql.DB) ([]Collection, error) {
+	rows, err := db.Query(`
+		SELECT collection_id, name, slug, description
+		FROM collections
+		ORDER BY name;
+		`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []Collection{}
+	for rows.Next() {
+		var collection Collection
+		if err := rows.Scan(&collection.CollectionID, &collection.Name, &collection.Slug, &collection.Description); err != nil {
+			return nil, err
+		}
+		collections = append(collections, collection)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	productIDs, err := getAllProductIDs(db)
+	if err != nil {
+		return nil, err
+	}
+	for i := range collections {
+		collections[i].ProductIDs = productIDs[collections[i].CollectionID]
+		if collections[i].ProductIDs == nil {
+			collections[i].ProductIDs = []int{}
+		}
+	}
+	return collections, nil
+}
+
+// getAllProductIDs loads the products of every collection in one query,
+// keyed by collection ID.
+func getAllProductIDs(db *sql.DB) (map[int][]int, error) {
+	rows, err := db.Query(`
+		SELECT collection_id, product_id
+		FROM collection_products
+		ORDER BY collection_id, position;
+		`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	productIDs := map[int][]int{}
+	for rows.Next() {
+		var collectionID, productID int
+		if err := rows.Scan(&collectionID, &productID); err != nil {
+			return nil, err
+		}
+		productIDs[collectionID] = append(productIDs[collectionID], productID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return productIDs, nil
+}
+
+func GetBySlug(slug string, db *sql.DB) (Collection, error) {
+	var collection Collection
+	err := db.QueryRow(`
+		SELECT collection_id, name, slug, description
+		FROM collections
+		WHERE slug = ?;
+		`, slug).Scan(&collection.CollectionID, &collection.Name, &collection.Slug, &collection.Description)
+	if err == sql.ErrNoRows {
+		return Collection{}, ErrNotFound
+	}
+	if err != nil {
+		return Collection{}, err
+	}
+	return collection, nil
+}
+
+func validate(collection Collection) (Collection, error) {
+	collection.Name = strings.TrimSpace(collection.Name)
+	collection.Description = strings.TrimSpace(collection.Description)
+	if collection.Name == "" || len(collection.Name) > 255 {
+		return Collection{}, fmt.Errorf("Invalid collection name! Please try again")
+	}
+	if len(collection.Description) > 1000 {
+		return Collection{}, fmt.Errorf("Collection description is too long")
+	}
+	slug, err := text.Slug(collection.Name, collection.Slug)
+	if err != nil {
+		return Collection{}, err
+	}
+	collection.Slug = slug
+	return collection, nil
+}
+
+func Create(collection Collection, actor audit.Actor, db *sql.DB) (Collection, error) {
+	collection, err := validate(collection)
+	if err != nil {
+		return Collection{}, err
+	}
+
+	transaction, err := db.Begin()
+	if err != nil {
+		return Collection{}, err
+	}
+	defer transaction.Rollback()
+
+	if err := checkSlug(transaction, 0, collection.Slug); err != nil {
+		return Collection{}, err
+	}
+	result, err := transaction.Exec(`
+		INSERT INTO collections (name, slug, description)
+		VALUES (?, ?, ?);
+		`, collection.Name, collection.Slug, collection.Description)
+	if err != nil {
+		return Collection{}, fmt.Errorf("Error creating collection! Please try again")
+	}
+	collectionID, err := result.LastInsertId()
+	if err != nil {
+		return Collection{}, err
+	}
+	collection.CollectionID = int(collectionID)
+
+	if collection.ProductIDs == nil {
+		collection.ProductIDs = []int{}
+	}
+	if collection.ProductIDs, err = setProducts(transaction, collection.CollectionID, collection.ProductIDs); err != nil {
+		return Collection{}, err
+	}
+
+	err = audit.Record(transaction, actor, audit.Change{
+		Action:     audit.ActionCreate,
+		EntityType: audit.EntityCollection,
+		EntityID:   strconv.Itoa(collection.CollectionID),
+		After:      collection,
+	})
+	if err != nil {
+		return Collection{}, err
+	}
+	if err := transaction.Commit(); err != nil {
+		return Collection{}, err
+	}
+	return collection, nil
+}
+
+// Update renames a collection and, when ProductIDs is given,
+// replaces its products in that order. Leaving ProductIDs out keeps the
+// current selection.
+func Update(collectionID int, collection Collection, actor audit.Actor, db *sql.DB) error {
+	collection, err := validate(collection)
+	if err != nil {
+		return err
+	}
+	collection.CollectionID = collectionID
+
+	transaction, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer transaction.Rollback()
+
+	before, err := getForUpdate(transaction, collectionID)
+	if err != nil {
+		return err
+	}
+	if err := checkSlug(transaction, collectionID, collection.Slug); err != nil {
+		return err
+	}
+
+	_, err = transaction.Exec(`
+		UPDATE collections
+		SET name = ?,
+			slug = ?,
+			description = ?
+		WHERE collection_id = ?;
+		`, collection.Name, collection.Slug, collection.Description, collectionID)
+	if err != nil {
+		return fmt.Errorf("Error updating collection! Please try again")
+	}
+
+	if collection.ProductIDs == nil {
+		collection.ProductIDs = before.ProductIDs
+	} else if collection.ProductIDs, err = setProducts(transaction, collectionID, collection.ProductIDs); err != nil {
+		return err
+	}
+
+	err = audit.Record(transaction, actor, audit.Change{
+		Action:     audit.ActionUpdate,
+		EntityType: audit.EntityCollection,
+		EntityID:   strconv.Itoa(collectionID),
+		Before:     before,
+		After:      collection,
+	})
+	if err != nil {
+		return err
+	}
+	return transaction.Commit()
+}
+
+func Delete(collectionID int, actor audit.Actor, db *sql.DB) error {
+	transaction, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer transaction.Rollback()
+
+	before, err := getForUpdate(transaction, collectionID)
+	if err != nil {
+		return err
+	}
+
+	_, err = transaction.Exec(`
+		DELETE FROM collection_products
+		WHERE collection_id = ?;
+		`, collectionID)
+	if err != nil {
+		return fmt.Errorf("Error deleting collection! Please try again")
+	}
+	_, err = transaction.Exec(`
+		DELETE FROM collections
+		WHERE collection_id = ?;
+		`, collectionID)
+	if err != nil {
+		return fmt.Errorf("Error deleting collection! Please try again")
+	}
+
+	err = audit.Record(transaction, actor, audit.Change{
+		Action:     audit.ActionDelete,
+		EntityType: audit.EntityCollection,
+		EntityID:   strconv.Itoa(collectionID),
+		Before:     before,
+	})
+	if err != nil {
+		return err
+	}
+	return transaction.Commit()
+}
+
+func getForUpdate(transaction *sql.Tx, collectionID int) (Collection, error) {
+	var collection Collection
+	err := transaction.QueryRow(`
+		SELECT collection_id, name, slug, description
+		FROM collections
+		WHERE collection_id = ?
+		FOR UPDATE;
+		`, collectionID).Scan(&collection.CollectionID, &collection.Name, &collection.Slug, &collection.Description)
+	if err == sql.ErrNoRows {
+		return Collection{}, ErrNotFound
+	}
+	if err != nil {
+		return Collection{}, err
+	}
+	collection.ProductIDs, err = getProductIDs(transaction, collectionID)
+	if err != nil {
+		return Collection{}, err
+	}
+	return collection, nil
+}
+
+func checkSlug(transaction *sql.Tx, collectionID int, slug string) error {
+	var taken bool
+	err := transaction.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1
+			FROM collections
+			WHERE slug = ? AND collection_id != ?
+		);
+		`, slug, collectionID).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return fmt.Errorf("This slug is already in use")
+	}
+	return nil
+}
+
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func getProductIDs(q querier, collectionID int) ([]int, error) {
+	rows, err := q.Query(`
+		SELECT product_id
+		FROM collection_products
+		WHERE collection_id = ?
+		ORDER BY position;
+		`, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	productIDs := []int{}
+	for rows.Next() {
+		var productID int
+		if err := rows.Scan(&productID); err != nil {
+			return nil, err
+		}
+		productIDs = append(productIDs, productID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return productIDs, nil
+}
+
+// setProducts replaces the products of a collection, keeping the
+// given order and dropping repeats. It returns the IDs actually stored.
+func setProducts(transaction *sql.Tx, collectionID int, productIDs []int) ([]int, error) {
+	_, err := transaction.Exec(`
+		DELETE FROM collection_products
+		WHERE collection_id = ?;
+		`, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := []int{}
+	seen := map[int]bool{}
+	for _, productID := range productIDs {
+		if seen[productID] {
+			continue
+		}
+		seen[productID] = true
+
+		result, err := transaction.Exec(`
+			INSERT INTO collection_products (collection_id, product_id, position)
+			SELECT ?, product_id, ?
+			FROM products
+			WHERE product_id = ?;
+			`, collectionID, len(stored), productID)
+		if err != nil {
+			return nil, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if affected == 0 {
+			return nil, fmt.Errorf("Unknown product %d", productID)
+		}
+		stored = append(stored, productID)
+	}
+	return stored, nil
+}
@@ -0,0 +1,352 @@
+package customers
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quyld17/E-Commerce-Website/entities/audit"
+	roles "github.com/quyld17/E-Commerce-Website/entities/role"
+	users "github.com/quyld17/E-Commerce-Website/entities/user"
+)
+
+const (
+	maxTags      = 20
+	maxTagLength = 50
+	maxNoteBody  = 2000
+)
+
+type Note struct {
+	NoteID           int       `json:"note_id"`
+	AuthorID         int       `json:"author_id"`
+	AuthorEmail      string    `json:"author_email"`
+	Body             string    `json:"body"`
+	CreatedAt        time.Time `json:"created_at"`
+	CreatedAtDisplay string    `json:"created_at_display"`
+}
+
+// SetStatus disables, bans or reactivates a customer. Every session of a
+// customer who loses access is revoked so they are signed out at once.
+func SetStatus(userID int, status, reason string, actor audit.Actor, db *sql.DB) error {
+	switch status {
+	case users.StatusActive:
+	case users.StatusDisabled, users.StatusBanned:
+		reason = strings.TrimSpace(reason)
+		if reason == "" || len(reason) > 255 {
+			return fmt.Errorf("Please give a reason of at most 255 characters")
+		}
+	default:
+		return fmt.Errorf("Status must be active, disabled or banned")
+	}
+
+	transaction, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer transaction.Rollback()
+
+	var roleID int
+	var previousStatus string
+	var previousReason sql.NullString
+	err = transaction.QueryRow(`
+		SELECT role_id, status, status_reason
+		FROM users
+		WHERE user_id = ? AND deleted_at IS NULL
+		FOR UPDATE;
+		`, userID).Scan(&roleID, &previousStatus, &previousReason)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("Customer not found")
+	}
+	if err != nil {
+		return err
+	}
+	if roleID != roles.CustomerRoleID {
+		return fmt.Errorf("Only customer accounts can be disabled here")
+	}
+
+	if status == users.StatusActive {
+		reason = ""
+	}
+	_, err = transaction.Exec(`
+		UPDATE users
+		SET status = ?,
+			status_reason = ?,
+			status_changed_at = CURRENT_TIMESTAMP
+		WHERE user_id = ?;
+		`, status, sql.NullString{String: reason, Valid: reason != ""}, userID)
+	if err != nil {
+		return fmt.Errorf("Error updating customer status! Please try again")
+	}
+
+	if status != users.StatusActive {
+		_, err = transaction.Exec(`
+			UPDATE sessions
+			SET revoked_at = CURRENT_TIMESTAMP
+			WHERE user_id = ? AND revoked_at IS NULL;
+			`, userID)
+		if err != nil {
+			return fmt.Errorf("Error updating customer status! Please try again")
+		}
+	}
+
+	err = audit.Record(transaction, actor, audit.Change{
+		Action:     audit.ActionUpdate,
+		EntityType: audit.EntityUser,
+		EntityID:   strconv.Itoa(userID),
+		Before:     map[string]string{"status": previousStatus, "status_reason": previousReason.String},
+		After:      map[string]string{"status": status, "status_reason": reason},
+	})
+	if err != nil {
+		return err
+	}
+	return transaction.Commit()
+}
+
+func GetNotes(userID int, db *sql.DB) ([]Note, error) {
+	rows, err := db.Query(`
+		SELECT
+			n.note_id,
+			n.author_id,
+			u.email,
+			n.body,
+			n.created_at
+		FROM customer_notes n
+		LEFT JOIN users u ON n.author_id = u.user_id
+		WHERE n.user_id = ?
+		ORDER BY n.created_at DESC, n.note_id DESC;
+		`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []Note{}
+	for rows.Next() {
+		var note Note
+		var authorID sql.NullInt64
+		var authorEmail sql.NullString
+		if err := rows.Scan(&note.NoteID, &authorID, &authorEmail, &note.Body, &note.CreatedAt); err != nil {
+			return nil, err
+		}
+		note.AuthorID = int(authorID.Int64)
+		note.AuthorEmail = authorEmail.String
+		note.CreatedAtDisplay = note.CreatedAt.Format("2006-01-02 15:04:05")
+		notes = append(notes, note)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+func AddNote(userID int, body string, actor audit.Actor, db *sql.DB) error {
+	body = strings.TrimSpace(body)
+	if body == "" || len(body) > maxNoteBody {
+		return fmt.Errorf("Note must be between 1 and %d characters", maxNoteBody)
+	}
+
+	transaction, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer transaction.Rollback()
+
+	if err := checkCustomer(transaction, userID); err != nil {
+		return err
+	}
+
+	result, err := transaction.Exec(`
+		INSERT INTO customer_notes (user_id, author_id, body)
+		VALUES (?, ?, ?);
+		`, userID, sql.NullInt64{Int64: int64(actor.UserID), Valid: actor.UserID != 0}, body)
+	if err != nil {
+		return fmt.Errorf("Error adding note! Please try again")
+	}
+	noteID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	err = audit.Record(transaction, actor, audit.Change{
+		Action:     audit.ActionCreate,
+		EntityType: audit.EntityNote,
+		EntityID:   strconv.FormatInt(noteID, 10),
+		After:      map[string]interface{}{"user_id": userID, "body": body},
+	})
+	if err != nil {
+		return err
+	}
+	return transaction.Commit()
+}
+
+func DeleteNote(userID, noteID int, actor audit.Actor, db *sql.DB) error {
+	transaction, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer transaction.Rollback()
+
+	var body string
+	err = transaction.QueryRow(`
+		SELECT body
+		FROM customer_notes
+		WHERE note_id = ? AND user_id = ?
+		FOR UPDATE;
+		`, noteID, userID).Scan(&body)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("Note not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = transaction.Exec(`
+		DELETE FROM customer_notes
+		WHERE note_id = ?;
+		`, noteID)
+	if err != nil {
+		return fmt.Errorf("Error deleting note! Please try again")
+	}
+
+	err = audit.Record(transaction, actor, audit.Change{
+		Action:     audit.ActionDelete,
+		EntityType: audit.EntityNote,
+		EntityID:   strconv.Itoa(noteID),
+		Before:     map[string]interface{}{"user_id": userID, "body": body},
+	})
+	if err != nil {
+		return err
+	}
+	return transaction.Commit()
+}
+
+func GetTags(userID int, db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`
+		SELECT tag
+		FROM customer_tags
+		WHERE user_id = ?
+		ORDER BY tag;
+		`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// SetTags replaces the tags of a customer. Tags are trimmed and duplicates
+// are dropped.
+func SetTags(userID int, tags []string, actor audit.Actor, db *sql.DB) error {
+	unique := map[string]bool{}
+	cleaned := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || unique[tag] {
+			continue
+		}
+		if len(tag) > maxTagLength {
+			return fmt.Errorf("Tags must be at most %d characters", maxTagLength)
+		}
+		unique[tag] = true
+		cleaned = append(cleaned, tag)
+	}
+	if len(cleaned) > maxTags {
+		return fmt.Errorf("A customer can have at most %d tags", maxTags)
+	}
+	sort.Strings(cleaned)
+
+	transaction, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer transaction.Rollback()
+
+	if err := checkCustomer(transaction, userID); err != nil {
+		return err
+	}
+
+	rows, err := transaction.Query(`
+		SELECT tag
+		FROM customer_tags
+		WHERE user_id = ?
+		ORDER BY tag;
+		`, userID)
+	if err != nil {
+		return err
+	}
+	previous := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			rows.Close()
+			return err
+		}
+		previous = append(previous, tag)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = transaction.Exec(`
+		DELETE FROM customer_tags
+		WHERE user_id = ?;
+		`, userID)
+	if err != nil {
+		return fmt.Errorf("Error updating tags! Please try again")
+	}
+	for _, tag := range cleaned {
+		_, err = transaction.Exec(`
+			INSERT INTO customer_tags (user_id, tag)
+			VALUES (?, ?);
+			`, userID, tag)
+		if err != nil {
+			return fmt.Errorf("Error updating tags! Please try again")
+		}
+	}
+
+	err = audit.Record(transaction, actor, audit.Change{
+		Action:     audit.ActionUpdate,
+		EntityType: audit.EntityUser,
+		EntityID:   strconv.Itoa(userID),
+		Before:     map[string][]string{"tags": previous},
+		After:      map[string][]string{"tags": cleaned},
+	})
+	if err != nil {
+		return err
+	}
+	return transaction.Commit()
+}
+
+func checkCustomer(transaction *sql.Tx, userID int) error {
+	var exists bool
+	err := transaction.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1
+			FROM users
+			WHERE user_id = ? AND role_id = ?
+		);
+		`, userID, roles.CustomerRoleID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("Customer not found")
+	}
+	return nil
+}
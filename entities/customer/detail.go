@@ -0,0 +1,149 @@
+package customers
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	roles "github.com/quyld17/E-Commerce-Website/entities/role"
+	users "github.com/quyld17/E-Commerce-Website/entities/user"
+)
+
+type Metrics struct {
+	OrderCount          int           `json:"order_count"`
+	LifetimeSpend       int           `json:"lifetime_spend"`
+	AverageOrderValue   int           `json:"average_order_value"`
+	FirstOrderAt        *time.Time    `json:"first_order_at"`
+	FirstOrderAtDisplay string        `json:"first_order_at_display"`
+	LastOrderAt         *time.Time    `json:"last_order_at"`
+	LastOrderAtDisplay  string        `json:"last_order_at_display"`
+	StatusBreakdown     []StatusCount `json:"status_breakdown"`
+}
+
+type StatusCount struct {
+	Status     string `json:"status"`
+	OrderCount int    `json:"order_count"`
+	TotalPrice int    `json:"total_price"`
+}
+
+type Profile struct {
+	users.User
+	StatusReason           string `json:"status_reason"`
+	StatusChangedAtDisplay string `json:"status_changed_at_display"`
+}
+
+// GetProfile returns a customer's account as support sees it, including why
+// it was disabled when it was.
+func GetProfile(userID int, db *sql.DB) (Profile, error) {
+	var profile Profile
+	var fullName, phoneNumber, avatarKey, statusReason sql.NullString
+	var gender sql.NullInt64
+	var dateOfBirth, verifiedAt, statusChangedAt sql.NullTime
+	err := db.QueryRow(`
+		SELECT
+			user_id,
+			email,
+			full_name,
+			date_of_birth,
+			phone_number,
+			gender,
+			created_at,
+			verified_at,
+			avatar_key,
+			status,
+			status_reason,
+			status_changed_at
+		FROM users
+		WHERE user_id = ? AND role_id = ?;
+		`, userID, roles.CustomerRoleID).Scan(
+		&profile.UserId,
+		&profile.Email,
+		&fullName,
+		&dateOfBirth,
+		&phoneNumber,
+		&gender,
+		&profile.CreatedAt,
+		&verifiedAt,
+		&avatarKey,
+		&profile.Status,
+		&statusReason,
+		&statusChangedAt)
+	if err == sql.ErrNoRows {
+		return Profile{}, fmt.Errorf("Customer not found")
+	}
+	if err != nil {
+		return Profile{}, err
+	}
+
+	profile.FullName = fullName.String
+	profile.PhoneNumber = phoneNumber.String
+	profile.Gender = int(gender.Int64)
+	profile.Verified = verifiedAt.Valid
+	profile.AvatarKey = avatarKey.String
+	profile.StatusReason = statusReason.String
+	profile.CreatedAtDisplay = profile.CreatedAt.Format("2006-01-02 15:04:05")
+	if dateOfBirth.Valid {
+		profile.DateOfBirth = dateOfBirth.Time
+		profile.DateOfBirthDisplay = dateOfBirth.Time.Format("2006-01-02")
+	}
+	if statusChangedAt.Valid {
+		profile.StatusChangedAtDisplay = statusChangedAt.Time.Format("2006-01-02 15:04:05")
+	}
+	return profile, nil
+}
+
+// GetMetrics sums up a customer's orders. All figures are computed by the
+// database rather than by loading the orders.
+func GetMetrics(userID int, db *sql.DB) (Metrics, error) {
+	var metrics Metrics
+	var firstOrderAt, lastOrderAt sql.NullTime
+	err := db.QueryRow(`
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(total_price), 0),
+			COALESCE(ROUND(AVG(total_price)), 0),
+			MIN(created_at),
+			MAX(created_at)
+		FROM `+"`orders`"+`
+		WHERE user_id = ?;
+		`, userID).Scan(&metrics.OrderCount, &metrics.LifetimeSpend, &metrics.AverageOrderValue, &firstOrderAt, &lastOrderAt)
+	if err != nil {
+		return Metrics{}, err
+	}
+	if firstOrderAt.Valid {
+		metrics.FirstOrderAt = &firstOrderAt.Time
+		metrics.FirstOrderAtDisplay = firstOrderAt.Time.Format("2006-01-02 15:04:05")
+	}
+	if lastOrderAt.Valid {
+		metrics.LastOrderAt = &lastOrderAt.Time
+		metrics.LastOrderAtDisplay = lastOrderAt.Time.Format("2006-01-02 15:04:05")
+	}
+
+	rows, err := db.Query(`
+		SELECT
+			status,
+			COUNT(*),
+			COALESCE(SUM(total_price), 0)
+		FROM `+"`orders`"+`
+		WHERE user_id = ?
+		GROUP BY status
+		ORDER BY COUNT(*) DESC, status;
+		`, userID)
+	if err != nil {
+		return Metrics{}, err
+	}
+	defer rows.Close()
+
+	metrics.StatusBreakdown = []StatusCount{}
+	for rows.Next() {
+		var statusCount StatusCount
+		if err := rows.Scan(&statusCount.Status, &statusCount.OrderCount, &statusCount.TotalPrice); err != nil {
+			return Metrics{}, err
+		}
+		metrics.StatusBreakdown = append(metrics.StatusBreakdown, statusCount)
+	}
+	if err = rows.Err(); err != nil {
+		return Metrics{}, err
+	}
+	return metrics, nil
+}
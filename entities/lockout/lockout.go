@@ -0,0 +1,214 @@
+package lockouts
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/quyld17/E-Commerce-Website/config"
+	"github.com/quyld17/E-Commerce-Website/entities/audit"
+)
+
+const (
+	ScopeEmail = "email"
+	ScopeIP    = "ip"
+)
+
+type Lockout struct {
+	LockoutID           int       `json:"lockout_id"`
+	Scope               string    `json:"scope"`
+	Identifier          string    `json:"identifier"`
+	FailedCount         int       `json:"failed_count"`
+	LockedUntil         time.Time `json:"locked_until"`
+	LockedUntilDisplay  string    `json:"locked_until_display"`
+	LastFailedAt        time.Time `json:"last_failed_at"`
+	LastFailedAtDisplay string    `json:"last_failed_at_display"`
+}
+
+// Check returns how long sign-in stays locked for the email or the IP, or
+// zero when neither is locked.
+func Check(email, ip string, db *sql.DB) (time.Duration, error) {
+	var lockedUntil sql.NullTime
+	err := db.QueryRow(`
+		SELECT MAX(locked_until)
+		FROM login_failures
+		WHERE 	(scope = ? AND identifier = ?) OR
+				(scope = ? AND identifier = ?);
+		`, ScopeEmail, email, ScopeIP, ip).Scan(&lockedUntil)
+	if err != nil {
+		return 0, err
+	}
+	if !lockedUntil.Valid {
+		return 0, nil
+	}
+
+	remaining := time.Until(lockedUntil.Time)
+	if remaining < 0 {
+		return 0, nil
+	}
+	return remaining, nil
+}
+
+func RecordFailure(email, ip string, policy config.Lockout, db *sql.DB) error {
+	if err := recordFailure(ScopeEmail, email, policy.EmailThreshold, policy, db); err != nil {
+		return err
+	}
+	return recordFailure(ScopeIP, ip, policy.IPThreshold, policy, db)
+}
+
+// RecordSuccess clears the failures of an email after a successful sign-in.
+// The IP counter is left alone so one valid account cannot be used to reset
+// the counter of an address that is stuffing credentials.
+func RecordSuccess(email string, db *sql.DB) error {
+	_, err := db.Exec(`
+		DELETE FROM login_failures
+		WHERE scope = ? AND identifier = ?;
+		`, ScopeEmail, email)
+	return err
+}
+
+// recordFailure counts a failure with a single upsert, so concurrent first
+// failures for the same key can not both try to insert its row. The counter
+// starts over when the previous failure is older than ResetAfter. MySQL
+// applies the assignments in order, so failed_count still sees the old
+// last_failed_at.
+func recordFailure(scope, identifier string, threshold int, policy config.Lockout, db *sql.DB) error {
+	transaction, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer transaction.Rollback()
+
+	_, err = transaction.Exec(`
+		INSERT INTO login_failures (scope, identifier, failed_count, last_failed_at)
+		VALUES (?, ?, 1, CURRENT_TIMESTAMP)
+		ON DUPLICATE KEY UPDATE
+			failed_count = IF(last_failed_at < CURRENT_TIMESTAMP - INTERVAL ? SECOND, 1, failed_count + 1),
+			last_failed_at = CURRENT_TIMESTAMP;
+		`, scope, identifier, int(policy.ResetAfter.Seconds()))
+	if err != nil {
+		return err
+	}
+
+	var lockoutID, failedCount int
+	err = transaction.QueryRow(`
+		SELECT lockout_id, failed_count
+		FROM login_failures
+		WHERE scope = ? AND identifier = ?;
+		`, scope, identifier).Scan(&lockoutID, &failedCount)
+	if err != nil {
+		return err
+	}
+
+	var lockedUntil sql.NullTime
+	if failedCount >= threshold {
+		lockedUntil = sql.NullTime{Time: time.Now().Add(lockoutDuration(failedCount-threshold, policy)), Valid: true}
+	}
+	_, err = transaction.Exec(`
+		UPDATE login_failures
+		SET locked_until = ?
+		WHERE lockout_id = ?;
+		`, lockedUntil, lockoutID)
+	if err != nil {
+		return err
+	}
+
+	return transaction.Commit()
+}
+
+func lockoutDuration(extraFailures int, policy config.Lockout) time.Duration {
+	duration := policy.BaseLockout
+	for i := 0; i < extraFailures && duration < policy.MaxLockout; i++ {
+		duration *= 2
+	}
+	if duration > policy.MaxLockout {
+		duration = policy.MaxLockout
+	}
+	return duration
+}
+
+func GetByPage(offset, limit int, db *sql.DB) ([]Lockout, error) {
+	rows, err := db.Query(`
+		SELECT
+			lockout_id,
+			scope,
+			identifier,
+			failed_count,
+			locked_until,
+			last_failed_at
+		FROM login_failures
+		ORDER BY locked_until IS NULL, locked_until DESC, last_failed_at DESC
+		LIMIT ? OFFSET ?;
+		`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lockouts := []Lockout{}
+	for rows.Next() {
+		var lockout Lockout
+		var lockedUntil sql.NullTime
+		err := rows.Scan(&lockout.LockoutID, &lockout.Scope, &lockout.Identifier, &lockout.FailedCount, &lockedUntil, &lockout.LastFailedAt)
+		if err != nil {
+			return nil, err
+		}
+		if lockedUntil.Valid {
+			lockout.LockedUntil = lockedUntil.Time
+			lockout.LockedUntilDisplay = lockedUntil.Time.Format("2006-01-02 15:04:05")
+		}
+		lockout.LastFailedAtDisplay = lockout.LastFailedAt.Format("2006-01-02 15:04:05")
+		lockouts = append(lockouts, lockout)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return lockouts, nil
+}
+
+func Clear(lockoutID int, actor audit.Actor, db *sql.DB) error {
+	transaction, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer transaction.Rollback()
+
+	var lockout Lockout
+	err = transaction.QueryRow(`
+		SELECT scope, identifier, failed_count
+		FROM login_failures
+		WHERE lockout_id = ?
+		FOR UPDATE;
+		`, lockoutID).Scan(&lockout.Scope, &lockout.Identifier, &lockout.FailedCount)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("Lockout not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = transaction.Exec(`
+		DELETE FROM login_failures
+		WHERE lockout_id = ?;
+		`, lockoutID)
+	if err != nil {
+		return err
+	}
+
+	err = audit.Record(transaction, actor, audit.Change{
+		Action:     audit.ActionDelete,
+		EntityType: audit.EntityLockout,
+		EntityID:   strconv.Itoa(lockoutID),
+		Before: map[string]interface{}{
+			"scope":        lockout.Scope,
+			"identifier":   lockout.Identifier,
+			"failed_count": lockout.FailedCount,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return transaction.Commit()
+}
@@ -2,21 +2,32 @@ package orders
 
 import (
 	"database/sql"
+	"errors"
+	"strconv"
 	"time"
 
 	"github.com/labstack/echo/v4"
+	addresses "github.com/quyld17/E-Commerce-Website/entities/address"
+	"github.com/quyld17/E-Commerce-Website/entities/audit"
 	"github.com/quyld17/E-Commerce-Website/entities/cart"
 	products "github.com/quyld17/E-Commerce-Website/entities/product"
 	users "github.com/quyld17/E-Commerce-Website/entities/user"
+	"github.com/quyld17/E-Commerce-Website/services/shipping"
 )
 
+var ErrNotFound = errors.New("Order not found")
+
 type Order struct {
 	OrderID          int            `json:"order_id"`
 	UserID           int            `json:"user_id"`
-	TotalPrice       int            `json:"total_price"`
+	SubtotalPrice    int               `json:"subtotal_price"`
+	ShippingMethod   string            `json:"shipping_method"`
+	ShippingFee      int               `json:"shipping_fee"`
+	TotalPrice       int               `json:"total_price"`
 	PaymentMethod    string         `json:"payment_method"`
-	Address          string         `json:"address"`
-	AddressID        int            `json:"address_id"`
+	Address          string            `json:"address"`
+	AddressID        int               `json:"address_id"`
+	ShippingAddress  addresses.Address `json:"shipping_address"`
 	Status           string         `json:"status"`
 	CreatedAt        time.Time      `json:"created_at"`
 	CreatedAtDisplay string         `json:"created_at_display"`
@@ -35,7 +46,10 @@ type OrderProduct struct {
 	SizeName    string `json:"size_name"`
 }
 
-func Create(orderedProducts []products.Product, userID, totalPrice int, paymenMethod, address string, c echo.Context, db *sql.DB) error {
+// Create places an order shipped to a snapshot of the given address, so later
+// edits to the address book never change where a past order went. The
+// shipping charge is kept apart from the products and added to the total.
+func Create(orderedProducts []products.Product, userID, subtotalPrice int, paymenMethod string, shippingAddress addresses.Address, shippingOption shipping.Option, c echo.Context, db *sql.DB) error {
 	transaction, err := db.Begin()
 	if err != nil {
 		return err
@@ -49,12 +63,31 @@ func Create(orderedProducts []products.Product, userID, totalPrice int, paymenMe
 	result, err := transaction.Exec(`
 		INSERT INTO`+"`orders`"+`
 			(user_id, 
+			subtotal_price,
+			shipping_method,
+			shipping_fee,
 			total_price, 
 			payment_method,
+			address_id,
 			address,
+			shipping_recipient_name,
+			shipping_phone_number,
+			shipping_street,
+			shipping_ward,
+			shipping_district,
+			shipping_province,
+			shipping_postal_code,
 			status) 
-		VALUES (?, ?, ?, ?, ?)
-		`, userID, totalPrice, paymenMethod, address, "Delivering")
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, userID, subtotalPrice, shippingOption.Method, shippingOption.Fee, subtotalPrice+shippingOption.Fee, paymenMethod, shippingAddress.AddressID, addresses.Format(shippingAddress),
+		shippingAddress.RecipientName,
+		shippingAddress.PhoneNumber,
+		shippingAddress.Street,
+		shippingAddress.Ward,
+		shippingAddress.District,
+		shippingAddress.Province,
+		shippingAddress.PostalCode,
+		"Delivering")
 	if err != nil {
 		return err
 	}
@@ -125,9 +158,20 @@ func GetByPage(userID int, c echo.Context, db *sql.DB) ([]Order, error) {
 	rows, err := db.Query(`
 		SELECT 
 			order_id,
+			subtotal_price,
+			shipping_method,
+			shipping_fee,
 			total_price,
 			status,
+			COALESCE(address_id, 0),
 			address,
+			shipping_recipient_name,
+			shipping_phone_number,
+			shipping_street,
+			shipping_ward,
+			shipping_district,
+			shipping_province,
+			shipping_postal_code,
 			created_at,
 			payment_method
 		FROM `+"`orders`"+`
@@ -143,7 +187,24 @@ func GetByPage(userID int, c echo.Context, db *sql.DB) ([]Order, error) {
 	orders := []Order{}
 	for rows.Next() {
 		var order Order
-		err := rows.Scan(&order.OrderID, &order.TotalPrice, &order.Status, &order.Address, &order.CreatedAt, &order.PaymentMethod)
+		err := rows.Scan(
+			&order.OrderID,
+			&order.SubtotalPrice,
+			&order.ShippingMethod,
+			&order.ShippingFee,
+			&order.TotalPrice,
+			&order.Status,
+			&order.AddressID,
+			&order.Address,
+			&order.ShippingAddress.RecipientName,
+			&order.ShippingAddress.PhoneNumber,
+			&order.ShippingAddress.Street,
+			&order.ShippingAddress.Ward,
+			&order.ShippingAddress.District,
+			&order.ShippingAddress.Province,
+			&order.ShippingAddress.PostalCode,
+			&order.CreatedAt,
+			&order.PaymentMethod)
 		if err != nil {
 			return nil, err
 		}
@@ -213,9 +274,20 @@ func GetByPageAdmin(offset, limit int, sortParam, search string, db *sql.DB) ([]
 			SELECT 
 				o.order_id,
 				o.user_id,
+				o.subtotal_price,
+				o.shipping_method,
+				o.shipping_fee,
 				o.total_price,
 				o.status,
+				COALESCE(o.address_id, 0),
 				o.address,
+				o.shipping_recipient_name,
+				o.shipping_phone_number,
+				o.shipping_street,
+				o.shipping_ward,
+				o.shipping_district,
+				o.shipping_province,
+				o.shipping_postal_code,
 				o.created_at,
 				o.payment_method,
 				u.email,
@@ -233,9 +305,20 @@ func GetByPageAdmin(offset, limit int, sortParam, search string, db *sql.DB) ([]
 			SELECT 
 				o.order_id,
 				o.user_id,
+				o.subtotal_price,
+				o.shipping_method,
+				o.shipping_fee,
 				o.total_price,	
 				o.status,
+				COALESCE(o.address_id, 0),
 				o.address,
+				o.shipping_recipient_name,
+				o.shipping_phone_number,
+				o.shipping_street,
+				o.shipping_ward,
+				o.shipping_district,
+				o.shipping_province,
+				o.shipping_postal_code,
 				o.created_at,
 				o.payment_method,
 				u.email,
@@ -260,9 +343,20 @@ func GetByPageAdmin(offset, limit int, sortParam, search string, db *sql.DB) ([]
 		err := rows.Scan(
 			&order.OrderID, 
 			&order.UserID,
+			&order.SubtotalPrice,
+			&order.ShippingMethod,
+			&order.ShippingFee,
 			&order.TotalPrice, 
 			&order.Status, 
+			&order.AddressID,
 			&order.Address, 
+			&order.ShippingAddress.RecipientName,
+			&order.ShippingAddress.PhoneNumber,
+			&order.ShippingAddress.Street,
+			&order.ShippingAddress.Ward,
+			&order.ShippingAddress.District,
+			&order.ShippingAddress.Province,
+			&order.ShippingAddress.PostalCode,
 			&order.CreatedAt,
 			&order.PaymentMethod, 
 			&order.User.Email, 
@@ -314,8 +408,28 @@ func GetByPageAdmin(offset, limit int, sortParam, search string, db *sql.DB) ([]
 }
 
 
-func Update(orderID int, status string, db *sql.DB) error {
-	_, err := db.Exec(`
+func Update(orderID int, status string, actor audit.Actor, db *sql.DB) error {
+	transaction, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer transaction.Rollback()
+
+	var previousStatus string
+	err = transaction.QueryRow(`
+		SELECT status
+		FROM `+"`orders`"+`
+		WHERE order_id = ?
+		FOR UPDATE;
+		`, orderID).Scan(&previousStatus)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = transaction.Exec(`
 		UPDATE orders
 		SET status = ?
 		WHERE order_id = ?;
@@ -323,5 +437,17 @@ func Update(orderID int, status string, db *sql.DB) error {
 	if err != nil {
 		return err
 	}
-	return nil
+
+	err = audit.Record(transaction, actor, audit.Change{
+		Action:     audit.ActionUpdate,
+		EntityType: audit.EntityOrder,
+		EntityID:   strconv.Itoa(orderID),
+		Before:     map[string]string{"status": previousStatus},
+		After:      map[string]string{"status": status},
+	})
+	if err != nil {
+		return err
+	}
+
+	return transaction.Commit()
 }
\ No newline at end of file
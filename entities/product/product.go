@@ -3,10 +3,14 @@ package products
 import (
 	"database/sql"
 	"errors"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
+	"github.com/quyld17/E-Commerce-Website/entities/audit"
 )
 
+var ErrNotFound = errors.New("Product not found")
+
 type Product struct {
 	ProductID     int    `json:"product_id"`
 	CartProductID int    `json:"cart_product_id"`
@@ -19,6 +23,7 @@ type Product struct {
 	SizeID        int    `json:"size_id"`
 	SizeName      string `json:"size_name"`
 	SizeQuantity  int    `json:"size_quantity"`
+	WeightGrams   int    `json:"weight_grams"`
 }
 
 type ProductImage struct {
@@ -40,12 +45,17 @@ type UpdateProductData struct {
 		Name          string  `json:"name"`
 		Price         float64 `json:"price"`
 		TotalQuantity int     `json:"total_quantity"`
+		WeightGrams   int     `json:"weight_grams"`
 	} `json:"product"`
 	Sizes []struct {
 		SizeName string `json:"size_name"`
 		Quantity int    `json:"quantity"`
 	} `json:"sizes"`
 	ImageURLs []string `json:"image_urls"`
+	// CategoryIDs and CollectionIDs replace the product's assignments when
+	// present. Leaving them out keeps the current ones.
+	CategoryIDs   []int `json:"category_ids"`
+	CollectionIDs []int `json:"collection_ids"`
 }
 
 func GetByPage(c echo.Context, db *sql.DB, limit, offset int, orderBy, search string) ([]Product, int, error) {
@@ -256,13 +266,18 @@ func Search(query string, db *sql.DB) ([]Product, error) {
 	return products, nil
 }
 
-func Delete(productID int, db *sql.DB) error {
+func Delete(productID int, actor audit.Actor, db *sql.DB) error {
 	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
+	before, err := getSnapshot(tx, productID)
+	if err != nil {
+		return err
+	}
+
 	_, err = tx.Exec(`
 		DELETE FROM product_images
 		WHERE product_id = ?;
@@ -279,6 +294,22 @@ func Delete(productID int, db *sql.DB) error {
 		return err
 	}
 
+	_, err = tx.Exec(`
+		DELETE FROM product_categories
+		WHERE product_id = ?;
+		`, productID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM collection_products
+		WHERE product_id = ?;
+		`, productID)
+	if err != nil {
+		return err
+	}
+
 	_, err = tx.Exec(`
 		DELETE FROM products
 		WHERE product_id = ?;
@@ -287,24 +318,40 @@ func Delete(productID int, db *sql.DB) error {
 		return err
 	}
 
+	err = audit.Record(tx, actor, audit.Change{
+		Action:     audit.ActionDelete,
+		EntityType: audit.EntityProduct,
+		EntityID:   strconv.Itoa(productID),
+		Before:     before,
+	})
+	if err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
-func Update(data UpdateProductData, db *sql.DB) error {
+func Update(data UpdateProductData, actor audit.Actor, db *sql.DB) error {
 	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
+	before, err := getSnapshot(tx, data.Product.ProductID)
+	if err != nil {
+		return err
+	}
+
 	_, err = tx.Exec(`
 		UPDATE products 
 		SET 
 			product_name = ?,
 			price = ?,
-			total_quantity = ?
+			total_quantity = ?,
+			weight_grams = ?
 		WHERE product_id = ?`,
-		data.Product.Name, data.Product.Price, data.Product.TotalQuantity, data.Product.ProductID)
+		data.Product.Name, data.Product.Price, data.Product.TotalQuantity, data.Product.WeightGrams, data.Product.ProductID)
 	if err != nil {
 		return err
 	}
@@ -346,10 +393,36 @@ func Update(data UpdateProductData, db *sql.DB) error {
 		}
 	}
 
+	if data.CategoryIDs != nil {
+		if err := setCategories(tx, data.Product.ProductID, data.CategoryIDs); err != nil {
+			return err
+		}
+	}
+	if data.CollectionIDs != nil {
+		if err := setCollections(tx, data.Product.ProductID, data.CollectionIDs); err != nil {
+			return err
+		}
+	}
+
+	after, err := getSnapshot(tx, data.Product.ProductID)
+	if err != nil {
+		return err
+	}
+	err = audit.Record(tx, actor, audit.Change{
+		Action:     audit.ActionUpdate,
+		EntityType: audit.EntityProduct,
+		EntityID:   strconv.Itoa(data.Product.ProductID),
+		Before:     before,
+		After:      after,
+	})
+	if err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
-func Add(data UpdateProductData, db *sql.DB) error {
+func Add(data UpdateProductData, actor audit.Actor, db *sql.DB) error {
 	tx, err := db.Begin()
 	if err != nil {
 		return err
@@ -357,9 +430,9 @@ func Add(data UpdateProductData, db *sql.DB) error {
 	defer tx.Rollback()
 	
 	result, err := tx.Exec(`
-		INSERT INTO products (product_name, price, total_quantity) 
-		VALUES (?, ?, ?)`,
-		data.Product.Name, data.Product.Price, data.Product.TotalQuantity)
+		INSERT INTO products (product_name, price, total_quantity, weight_grams) 
+		VALUES (?, ?, ?, ?)`,
+		data.Product.Name, data.Product.Price, data.Product.TotalQuantity, data.Product.WeightGrams)
 	if err != nil {
 		return err
 	}
@@ -392,9 +465,109 @@ func Add(data UpdateProductData, db *sql.DB) error {
 		}
 	}
 
+	if err := setCategories(tx, int(productID), data.CategoryIDs); err != nil {
+		return err
+	}
+	if err := setCollections(tx, int(productID), data.CollectionIDs); err != nil {
+		return err
+	}
+
+	after, err := getSnapshot(tx, int(productID))
+	if err != nil {
+		return err
+	}
+	err = audit.Record(tx, actor, audit.Change{
+		Action:     audit.ActionCreate,
+		EntityType: audit.EntityProduct,
+		EntityID:   strconv.FormatInt(productID, 10),
+		After:      after,
+	})
+	if err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
+// snapshot is the state of a product as recorded in the audit log.
+type snapshot struct {
+	ProductName   string         `json:"product_name"`
+	Price         int            `json:"price"`
+	TotalQuantity int            `json:"total_quantity"`
+	WeightGrams   int            `json:"weight_grams"`
+	Sizes         map[string]int `json:"sizes"`
+	ImageURLs     []string       `json:"image_urls"`
+	CategoryIDs   []int          `json:"category_ids"`
+	CollectionIDs []int          `json:"collection_ids"`
+}
+
+func getSnapshot(tx *sql.Tx, productID int) (*snapshot, error) {
+	product := snapshot{Sizes: map[string]int{}, ImageURLs: []string{}}
+	err := tx.QueryRow(`
+		SELECT product_name, price, total_quantity, weight_grams
+		FROM products
+		WHERE product_id = ?
+		FOR UPDATE;
+		`, productID).Scan(&product.ProductName, &product.Price, &product.TotalQuantity, &product.WeightGrams)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sizeRows, err := tx.Query(`
+		SELECT size_name, quantity
+		FROM sizes
+		WHERE product_id = ?;
+		`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer sizeRows.Close()
+	for sizeRows.Next() {
+		var sizeName string
+		var quantity int
+		if err := sizeRows.Scan(&sizeName, &quantity); err != nil {
+			return nil, err
+		}
+		product.Sizes[sizeName] = quantity
+	}
+	if err := sizeRows.Err(); err != nil {
+		return nil, err
+	}
+
+	imageRows, err := tx.Query(`
+		SELECT image_url
+		FROM product_images
+		WHERE product_id = ?
+		ORDER BY is_thumbnail DESC, image_id;
+		`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer imageRows.Close()
+	for imageRows.Next() {
+		var imageURL string
+		if err := imageRows.Scan(&imageURL); err != nil {
+			return nil, err
+		}
+		product.ImageURLs = append(product.ImageURLs, imageURL)
+	}
+	if err := imageRows.Err(); err != nil {
+		return nil, err
+	}
+
+	if product.CategoryIDs, err = getCategoryIDs(tx, productID); err != nil {
+		return nil, err
+	}
+	if product.CollectionIDs, err = getCollectionIDs(tx, productID); err != nil {
+		return nil, err
+	}
+
+	return &product, nil
+}
+
 func CheckProductExists(productID int, db *sql.DB) error {
 	var exists bool
 	err := db.QueryRow(`
@@ -409,7 +582,7 @@ func CheckProductExists(productID int, db *sql.DB) error {
 		return err
 	}
 	if !exists {
-		return errors.New("Product not found")
+		return ErrNotFound
 	}
 
 	return nil
@@ -0,0 +1,222 @@
+package products
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrUnknownCategory   = errors.New("Unknown category")
+	ErrUnknownCollection = errors.New("Unknown collection")
+)
+
+// GetByCategories lists the in-stock products filed under any of the given
+// categories, with the same paging and sorting as GetByPage.
+func GetByCategories(categoryIDs []int, limit, offset int, orderBy string, db *sql.DB) ([]Product, int, error) {
+	if len(categoryIDs) == 0 {
+		return []Product{}, 0, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(categoryIDs)), ", ")
+	args := []interface{}{}
+	for _, categoryID := range categoryIDs {
+		args = append(args, categoryID)
+	}
+
+	filter := `products.product_id IN (
+		SELECT product_id
+		FROM product_categories
+		WHERE category_id IN (` + placeholders + `)
+	)`
+	return getPage("", filter, args, limit, offset, orderBy, db)
+}
+
+// GetByCollection lists the in-stock products of a collection. An empty
+// orderBy keeps the order the collection was curated in.
+func GetByCollection(collectionID, limit, offset int, orderBy string, db *sql.DB) ([]Product, int, error) {
+	if orderBy == "" {
+		orderBy = "collection_products.position ASC"
+	}
+	join := "JOIN collection_products ON products.product_id = collection_products.product_id"
+	return getPage(join, "collection_products.collection_id = ?", []interface{}{collectionID}, limit, offset, orderBy, db)
+}
+
+func getPage(join, filter string, args []interface{}, limit, offset int, orderBy string, db *sql.DB) ([]Product, int, error) {
+	from := `
+		FROM products
+		JOIN product_images ON products.product_id = product_images.product_id
+		` + join + `
+		WHERE
+			product_images.is_thumbnail = 1 AND
+			products.total_quantity > 0 AND
+			` + filter
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) `+from+`;`, args...).Scan(&count); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query(`
+		SELECT
+			products.product_id,
+			products.product_name,
+			products.price,
+			product_images.image_url,
+			products.total_quantity
+		`+from+`
+		ORDER BY `+orderBy+`
+		LIMIT ?
+		OFFSET ?;
+		`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	productDetails := []Product{}
+	for rows.Next() {
+		var product Product
+		err := rows.Scan(&product.ProductID, &product.ProductName, &product.Price, &product.ImageURL, &product.TotalQuantity)
+		if err != nil {
+			return nil, 0, err
+		}
+		productDetails = append(productDetails, product)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return productDetails, count, nil
+}
+
+func setCategories(tx *sql.Tx, productID int, categoryIDs []int) error {
+	_, err := tx.Exec(`
+		DELETE FROM product_categories
+		WHERE product_id = ?;
+		`, productID)
+	if err != nil {
+		return err
+	}
+
+	seen := map[int]bool{}
+	for _, categoryID := range categoryIDs {
+		if seen[categoryID] {
+			continue
+		}
+		seen[categoryID] = true
+
+		result, err := tx.Exec(`
+			INSERT INTO product_categories (product_id, category_id)
+			SELECT ?, category_id
+			FROM categories
+			WHERE category_id = ?;
+			`, productID, categoryID)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return fmt.Errorf("%w %d", ErrUnknownCategory, categoryID)
+		}
+	}
+	return nil
+}
+
+// setCollections puts the product in exactly the given collections. It keeps
+// its place in collections it already belonged to and goes to the end of
+// the ones it joins.
+func setCollections(tx *sql.Tx, productID int, collectionIDs []int) error {
+	current, err := getCollectionIDs(tx, productID)
+	if err != nil {
+		return err
+	}
+	wanted := map[int]bool{}
+	for _, collectionID := range collectionIDs {
+		wanted[collectionID] = true
+	}
+
+	for _, collectionID := range current {
+		if wanted[collectionID] {
+			delete(wanted, collectionID)
+			continue
+		}
+		_, err := tx.Exec(`
+			DELETE FROM collection_products
+			WHERE collection_id = ? AND product_id = ?;
+			`, collectionID, productID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, collectionID := range collectionIDs {
+		if !wanted[collectionID] {
+			continue
+		}
+		delete(wanted, collectionID)
+
+		result, err := tx.Exec(`
+			INSERT INTO collection_products (collection_id, product_id, position)
+			SELECT c.collection_id, ?, (
+				SELECT COALESCE(MAX(cp.position) + 1, 0)
+				FROM collection_products cp
+				WHERE cp.collection_id = c.collection_id
+			)
+			FROM collections c
+			WHERE c.collection_id = ?;
+			`, productID, collectionID)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return fmt.Errorf("%w %d", ErrUnknownCollection, collectionID)
+		}
+	}
+	return nil
+}
+
+func getCategoryIDs(tx *sql.Tx, productID int) ([]int, error) {
+	return getIDs(tx, `
+		SELECT category_id
+		FROM product_categories
+		WHERE product_id = ?;
+		`, productID)
+}
+
+func getCollectionIDs(tx *sql.Tx, productID int) ([]int, error) {
+	return getIDs(tx, `
+		SELECT collection_id
+		FROM collection_products
+		WHERE product_id = ?;
+		`, productID)
+}
+
+func getIDs(tx *sql.Tx, query string, productID int) ([]int, error) {
+	rows, err := tx.Query(query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
@@ -0,0 +1,392 @@
+package roles
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/quyld17/E-Commerce-Website/entities/audit"
+)
+
+const (
+	ProductsRead         = "products:read"
+	ProductsWrite        = "products:write"
+	ProductsDelete       = "products:delete"
+	OrdersRead           = "orders:read"
+	OrdersUpdate         = "orders:update"
+	CustomersRead        = "customers:read"
+	CustomersImpersonate = "customers:impersonate"
+	CustomersManage      = "customers:manage"
+	LockoutsManage       = "lockouts:manage"
+	RolesManage          = "roles:manage"
+	APIKeysManage        = "api_keys:manage"
+	AuditLogRead         = "audit_log:read"
+)
+
+const (
+	CustomerRoleID   = 1
+	CustomerRoleName = "Customer"
+	AdminRoleName    = "Admin"
+)
+
+type Role struct {
+	RoleID      int      `json:"role_id"`
+	RoleName    string   `json:"role_name"`
+	Permissions []string `json:"permissions"`
+}
+
+type Permission struct {
+	PermissionID   int    `json:"permission_id"`
+	PermissionName string `json:"permission_name"`
+	Description    string `json:"description"`
+}
+
+type StaffMember struct {
+	UserID   int    `json:"user_id"`
+	Email    string `json:"email"`
+	FullName string `json:"full_name"`
+	RoleID   int    `json:"role_id"`
+	RoleName string `json:"role_name"`
+}
+
+func HasPermission(roleName, permission string, db *sql.DB) (bool, error) {
+	var allowed bool
+	err := db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1
+			FROM roles r
+			JOIN role_permissions rp ON r.role_id = rp.role_id
+			JOIN permissions p ON rp.permission_id = p.permission_id
+			WHERE r.role_name = ? AND p.permission_name = ?
+		);
+		`, roleName, permission).Scan(&allowed)
+	if err != nil {
+		return false, err
+	}
+	return allowed, nil
+}
+
+func GetAll(db *sql.DB) ([]Role, error) {
+	rows, err := db.Query(`
+		SELECT
+			r.role_id,
+			r.role_name,
+			p.permission_name
+		FROM roles r
+		LEFT JOIN role_permissions rp ON r.role_id = rp.role_id
+		LEFT JOIN permissions p ON rp.permission_id = p.permission_id
+		ORDER BY r.role_id, p.permission_name;
+		`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []Role{}
+	for rows.Next() {
+		var role Role
+		var permission sql.NullString
+		if err := rows.Scan(&role.RoleID, &role.RoleName, &permission); err != nil {
+			return nil, err
+		}
+		if len(roles) == 0 || roles[len(roles)-1].RoleID != role.RoleID {
+			role.Permissions = []string{}
+			roles = append(roles, role)
+		}
+		if permission.Valid {
+			last := &roles[len(roles)-1]
+			last.Permissions = append(last.Permissions, permission.String)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func GetPermissions(db *sql.DB) ([]Permission, error) {
+	rows, err := db.Query(`
+		SELECT permission_id, permission_name, description
+		FROM permissions
+		ORDER BY permission_name;
+		`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []Permission{}
+	for rows.Next() {
+		var permission Permission
+		if err := rows.Scan(&permission.PermissionID, &permission.PermissionName, &permission.Description); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+func Create(roleName string, permissions []string, actor audit.Actor, db *sql.DB) error {
+	roleName = strings.TrimSpace(roleName)
+	if roleName == "" || len(roleName) > 255 {
+		return fmt.Errorf("Invalid role name! Please try again")
+	}
+
+	transaction, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer transaction.Rollback()
+
+	var exists bool
+	err = transaction.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1
+			FROM roles
+			WHERE role_name = ?
+		);
+		`, roleName).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("Role with this name already exists!")
+	}
+
+	result, err := transaction.Exec(`
+		INSERT INTO roles (role_name)
+		VALUES (?);
+		`, roleName)
+	if err != nil {
+		return err
+	}
+	roleID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	if err := setPermissions(transaction, int(roleID), permissions); err != nil {
+		return err
+	}
+
+	after, err := getPermissionNames(transaction, int(roleID))
+	if err != nil {
+		return err
+	}
+	err = audit.Record(transaction, actor, audit.Change{
+		Action:     audit.ActionCreate,
+		EntityType: audit.EntityRole,
+		EntityID:   strconv.FormatInt(roleID, 10),
+		After:      Role{RoleID: int(roleID), RoleName: roleName, Permissions: after},
+	})
+	if err != nil {
+		return err
+	}
+	return transaction.Commit()
+}
+
+// SetPermissions replaces the permissions of a role. The customer role never
+// gets permissions and the Admin role always keeps all of them, so nobody can
+// lock the shop out of its own back office.
+func SetPermissions(roleID int, permissions []string, actor audit.Actor, db *sql.DB) error {
+	if roleID == CustomerRoleID {
+		return fmt.Errorf("The customer role can not have staff permissions")
+	}
+
+	transaction, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer transaction.Rollback()
+
+	var roleName string
+	err = transaction.QueryRow(`
+		SELECT role_name
+		FROM roles
+		WHERE role_id = ?
+		FOR UPDATE;
+		`, roleID).Scan(&roleName)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("Role not found")
+	}
+	if err != nil {
+		return err
+	}
+	if roleName == AdminRoleName {
+		return fmt.Errorf("The Admin role always has every permission")
+	}
+
+	before, err := getPermissionNames(transaction, roleID)
+	if err != nil {
+		return err
+	}
+	if err := setPermissions(transaction, roleID, permissions); err != nil {
+		return err
+	}
+	after, err := getPermissionNames(transaction, roleID)
+	if err != nil {
+		return err
+	}
+
+	err = audit.Record(transaction, actor, audit.Change{
+		Action:     audit.ActionUpdate,
+		EntityType: audit.EntityRole,
+		EntityID:   strconv.Itoa(roleID),
+		Before:     Role{RoleID: roleID, RoleName: roleName, Permissions: before},
+		After:      Role{RoleID: roleID, RoleName: roleName, Permissions: after},
+	})
+	if err != nil {
+		return err
+	}
+	return transaction.Commit()
+}
+
+func getPermissionNames(transaction *sql.Tx, roleID int) ([]string, error) {
+	rows, err := transaction.Query(`
+		SELECT p.permission_name
+		FROM role_permissions rp
+		JOIN permissions p ON rp.permission_id = p.permission_id
+		WHERE rp.role_id = ?;
+		`, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []string{}
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(permissions)
+	return permissions, nil
+}
+
+func setPermissions(transaction *sql.Tx, roleID int, permissions []string) error {
+	_, err := transaction.Exec(`
+		DELETE FROM role_permissions
+		WHERE role_id = ?;
+		`, roleID)
+	if err != nil {
+		return err
+	}
+
+	for _, permission := range permissions {
+		result, err := transaction.Exec(`
+			INSERT INTO role_permissions (role_id, permission_id)
+			SELECT ?, permission_id
+			FROM permissions
+			WHERE permission_name = ?;
+			`, roleID, permission)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return fmt.Errorf("Unknown permission %q", permission)
+		}
+	}
+	return nil
+}
+
+func Assign(userID, roleID int, actor audit.Actor, db *sql.DB) error {
+	transaction, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer transaction.Rollback()
+
+	var previousRoleID int
+	err = transaction.QueryRow(`
+		SELECT role_id
+		FROM users
+		WHERE user_id = ?
+		FOR UPDATE;
+		`, userID).Scan(&previousRoleID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("User not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	var roleExists bool
+	err = transaction.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM roles WHERE role_id = ?);
+		`, roleID).Scan(&roleExists)
+	if err != nil {
+		return err
+	}
+	if !roleExists {
+		return fmt.Errorf("Role not found")
+	}
+
+	_, err = transaction.Exec(`
+		UPDATE users
+		SET role_id = ?
+		WHERE user_id = ?;
+		`, roleID, userID)
+	if err != nil {
+		return fmt.Errorf("Error assigning role! Please try again")
+	}
+
+	err = audit.Record(transaction, actor, audit.Change{
+		Action:     audit.ActionUpdate,
+		EntityType: audit.EntityUser,
+		EntityID:   strconv.Itoa(userID),
+		Before:     map[string]int{"role_id": previousRoleID},
+		After:      map[string]int{"role_id": roleID},
+	})
+	if err != nil {
+		return err
+	}
+	return transaction.Commit()
+}
+
+func GetStaff(db *sql.DB) ([]StaffMember, error) {
+	rows, err := db.Query(`
+		SELECT
+			u.user_id,
+			u.email,
+			u.full_name,
+			r.role_id,
+			r.role_name
+		FROM users u
+		JOIN roles r ON u.role_id = r.role_id
+		WHERE u.role_id != ?
+		ORDER BY r.role_id, u.email;
+		`, CustomerRoleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	staff := []StaffMember{}
+	for rows.Next() {
+		var member StaffMember
+		var fullName sql.NullString
+		if err := rows.Scan(&member.UserID, &member.Email, &fullName, &member.RoleID, &member.RoleName); err != nil {
+			return nil, err
+		}
+		member.FullName = fullName.String
+		staff = append(staff, member)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return staff, nil
+}
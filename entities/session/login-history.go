@@ -0,0 +1,80 @@
+package sessions
+
+import (
+	"database/sql"
+	"time"
+)
+
+type Login struct {
+	LoginID          int       `json:"login_id"`
+	SessionID        string    `json:"session_id"`
+	IPAddress        string    `json:"ip_address"`
+	UserAgent        string    `json:"user_agent"`
+	NewDevice        bool      `json:"new_device"`
+	CreatedAt        time.Time `json:"created_at"`
+	CreatedAtDisplay string    `json:"created_at_display"`
+}
+
+// GetLoginHistory lists a user's successful sign-ins, newest first.
+func GetLoginHistory(userID, offset, limit int, db *sql.DB) ([]Login, error) {
+	rows, err := db.Query(`
+		SELECT
+			login_id,
+			session_id,
+			ip_address,
+			user_agent,
+			new_device,
+			created_at
+		FROM login_history
+		WHERE user_id = ?
+		ORDER BY created_at DESC, login_id DESC
+		LIMIT ? OFFSET ?;
+		`, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logins := []Login{}
+	for rows.Next() {
+		var login Login
+		err := rows.Scan(&login.LoginID, &login.SessionID, &login.IPAddress, &login.UserAgent, &login.NewDevice, &login.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		login.CreatedAtDisplay = login.CreatedAt.Format("2006-01-02 15:04:05")
+		logins = append(logins, login)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return logins, nil
+}
+
+// recordLogin adds a sign-in to the login history and reports whether it came
+// from an IP or user agent the account has not used before. The very first
+// sign-in of an account is never flagged.
+func recordLogin(transaction *sql.Tx, userID int, sessionID string, client Client) (bool, error) {
+	var previous, knownIP, knownAgent int
+	err := transaction.QueryRow(`
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(ip_address = ?), 0),
+			COALESCE(SUM(user_agent = ?), 0)
+		FROM login_history
+		WHERE user_id = ?;
+		`, client.IPAddress, client.UserAgent, userID).Scan(&previous, &knownIP, &knownAgent)
+	if err != nil {
+		return false, err
+	}
+	newDevice := previous > 0 && (knownIP == 0 || knownAgent == 0)
+
+	_, err = transaction.Exec(`
+		INSERT INTO login_history (user_id, session_id, ip_address, user_agent, new_device)
+		VALUES (?, ?, ?, ?, ?);
+		`, userID, sessionID, client.IPAddress, client.UserAgent, newDevice)
+	if err != nil {
+		return false, err
+	}
+	return newDevice, nil
+}
@@ -0,0 +1,364 @@
+package sessions
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	users "github.com/quyld17/E-Commerce-Website/entities/user"
+	"github.com/quyld17/E-Commerce-Website/services/token"
+)
+
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+var ErrInvalidRefreshToken = errors.New("Invalid or expired refresh token! Please sign in again")
+var ErrRefreshTokenReused = errors.New("Refresh token has already been used! Please sign in again")
+var ErrSessionRevoked = errors.New("Session has been revoked! Please sign in again")
+var ErrSessionNotFound = errors.New("Session not found")
+
+type Session struct {
+	SessionID         string    `json:"session_id"`
+	UserID            int       `json:"user_id"`
+	MFAVerified       bool      `json:"mfa_verified"`
+	IPAddress         string    `json:"ip_address"`
+	UserAgent         string    `json:"user_agent"`
+	CreatedAt         time.Time `json:"created_at"`
+	CreatedAtDisplay  string    `json:"created_at_display"`
+	LastUsedAt        time.Time `json:"last_used_at"`
+	LastUsedAtDisplay string    `json:"last_used_at_display"`
+	Current           bool      `json:"current"`
+	NewDevice         bool      `json:"-"`
+}
+
+// Client describes where a sign-in came from.
+type Client struct {
+	IPAddress string
+	UserAgent string
+}
+
+// Create starts a new session for a signed-in user and returns it together
+// with the first refresh token of the session. The sign-in is added to the
+// login history, and NewDevice is set when the client's IP or user agent has
+// not been seen on this account before.
+func Create(userID int, mfaVerified bool, client Client, db *sql.DB) (Session, string, error) {
+	sessionID, err := token.NewID()
+	if err != nil {
+		return Session{}, "", err
+	}
+	refreshToken, err := token.New(32)
+	if err != nil {
+		return Session{}, "", err
+	}
+
+	transaction, err := db.Begin()
+	if err != nil {
+		return Session{}, "", err
+	}
+	defer transaction.Rollback()
+
+	client.UserAgent = truncate(client.UserAgent, 255)
+
+	_, err = transaction.Exec(`
+		INSERT INTO sessions (session_id, user_id, mfa_verified, ip_address, user_agent)
+		VALUES (?, ?, ?, ?, ?);
+		`, sessionID, userID, mfaVerified, client.IPAddress, client.UserAgent)
+	if err != nil {
+		return Session{}, "", err
+	}
+
+	if err := insertRefreshToken(transaction, sessionID, refreshToken); err != nil {
+		return Session{}, "", err
+	}
+
+	newDevice, err := recordLogin(transaction, userID, sessionID, client)
+	if err != nil {
+		return Session{}, "", err
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return Session{}, "", err
+	}
+	return Session{
+		SessionID:   sessionID,
+		UserID:      userID,
+		MFAVerified: mfaVerified,
+		IPAddress:   client.IPAddress,
+		UserAgent:   client.UserAgent,
+		NewDevice:   newDevice,
+	}, refreshToken, nil
+}
+
+// GetActive lists the sessions of a user that have not been revoked and can
+// still be refreshed, most recently used first.
+func GetActive(userID int, db *sql.DB) ([]Session, error) {
+	rows, err := db.Query(`
+		SELECT
+			session_id,
+			user_id,
+			mfa_verified,
+			ip_address,
+			user_agent,
+			created_at,
+			last_used_at
+		FROM sessions
+		WHERE
+			user_id = ? AND
+			revoked_at IS NULL AND
+			last_used_at > ?
+		ORDER BY last_used_at DESC;
+		`, userID, time.Now().Add(-RefreshTokenTTL))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(&session.SessionID, &session.UserID, &session.MFAVerified, &session.IPAddress, &session.UserAgent, &session.CreatedAt, &session.LastUsedAt)
+		if err != nil {
+			return nil, err
+		}
+		session.CreatedAtDisplay = session.CreatedAt.Format("2006-01-02 15:04:05")
+		session.LastUsedAtDisplay = session.LastUsedAt.Format("2006-01-02 15:04:05")
+		sessions = append(sessions, session)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Refresh exchanges a refresh token for a new one in the same session. Each
+// refresh token can only be used once; presenting a used token again means it
+// has leaked, so the whole session is revoked.
+func Refresh(refreshToken string, db *sql.DB) (Session, string, error) {
+	transaction, err := db.Begin()
+	if err != nil {
+		return Session{}, "", err
+	}
+	defer transaction.Rollback()
+
+	var session Session
+	var tokenID int
+	var expiresAt time.Time
+	var usedAt, revokedAt sql.NullTime
+	err = transaction.QueryRow(`
+		SELECT
+			rt.token_id,
+			rt.expires_at,
+			rt.used_at,
+			s.session_id,
+			s.user_id,
+			s.mfa_verified,
+			s.revoked_at
+		FROM refresh_tokens rt
+		JOIN sessions s ON rt.session_id = s.session_id
+		WHERE rt.token_hash = ?
+		FOR UPDATE;
+		`, token.Hash(refreshToken)).Scan(&tokenID, &expiresAt, &usedAt, &session.SessionID, &session.UserID, &session.MFAVerified, &revokedAt)
+	if err == sql.ErrNoRows {
+		return Session{}, "", ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return Session{}, "", err
+	}
+
+	if revokedAt.Valid {
+		return Session{}, "", ErrSessionRevoked
+	}
+
+	if usedAt.Valid {
+		if err := revoke(transaction, session.SessionID); err != nil {
+			return Session{}, "", err
+		}
+		if err := transaction.Commit(); err != nil {
+			return Session{}, "", err
+		}
+		return Session{}, "", ErrRefreshTokenReused
+	}
+
+	if time.Now().After(expiresAt) {
+		return Session{}, "", ErrInvalidRefreshToken
+	}
+
+	_, err = transaction.Exec(`
+		UPDATE refresh_tokens
+		SET used_at = CURRENT_TIMESTAMP
+		WHERE token_id = ?;
+		`, tokenID)
+	if err != nil {
+		return Session{}, "", err
+	}
+
+	_, err = transaction.Exec(`
+		UPDATE sessions
+		SET last_used_at = CURRENT_TIMESTAMP
+		WHERE session_id = ?;
+		`, session.SessionID)
+	if err != nil {
+		return Session{}, "", err
+	}
+
+	newRefreshToken, err := token.New(32)
+	if err != nil {
+		return Session{}, "", err
+	}
+	if err := insertRefreshToken(transaction, session.SessionID, newRefreshToken); err != nil {
+		return Session{}, "", err
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return Session{}, "", err
+	}
+	return session, newRefreshToken, nil
+}
+
+// CheckActive reports whether an access token may still be used: its session
+// must not be revoked, its token ID must not be on the denylist and the
+// account must not be disabled.
+func CheckActive(sessionID, tokenID string, db *sql.DB) error {
+	var revokedAt sql.NullTime
+	var tokenRevoked bool
+	var status string
+	err := db.QueryRow(`
+		SELECT
+			s.revoked_at,
+			EXISTS (
+				SELECT 1
+				FROM revoked_tokens
+				WHERE jti = ?
+			),
+			u.status
+		FROM sessions s
+		JOIN users u ON s.user_id = u.user_id
+		WHERE s.session_id = ?;
+		`, tokenID, sessionID).Scan(&revokedAt, &tokenRevoked, &status)
+	if err == sql.ErrNoRows {
+		return ErrSessionRevoked
+	}
+	if err != nil {
+		return err
+	}
+	if err := users.StatusError(status); err != nil {
+		return err
+	}
+	if revokedAt.Valid || tokenRevoked {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+// RevokeToken puts an access token on the denylist until it expires.
+func RevokeToken(tokenID string, expiresAt time.Time, db *sql.DB) error {
+	_, err := db.Exec(`
+		INSERT IGNORE INTO revoked_tokens (jti, expires_at)
+		VALUES (?, ?);
+		`, tokenID, expiresAt)
+	if err != nil {
+		return fmt.Errorf("Error signing out! Please try again")
+	}
+	return nil
+}
+
+// PruneRevokedTokens removes denylist entries whose tokens have expired and
+// would be rejected anyway.
+func PruneRevokedTokens(db *sql.DB) error {
+	_, err := db.Exec(`
+		DELETE FROM revoked_tokens
+		WHERE expires_at < CURRENT_TIMESTAMP;
+		`)
+	return err
+}
+
+func PruneRevokedTokensEvery(interval time.Duration, db *sql.DB) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := PruneRevokedTokens(db); err != nil {
+			log.Println("Failed to prune revoked tokens:", err)
+		}
+	}
+}
+
+func Revoke(sessionID string, db *sql.DB) error {
+	transaction, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer transaction.Rollback()
+
+	if err := revoke(transaction, sessionID); err != nil {
+		return err
+	}
+	return transaction.Commit()
+}
+
+// RevokeForUser revokes one of the user's own sessions. Sessions belonging to
+// other users are reported as not found.
+func RevokeForUser(sessionID string, userID int, db *sql.DB) error {
+	result, err := db.Exec(`
+		UPDATE sessions
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE session_id = ? AND user_id = ? AND revoked_at IS NULL;
+		`, sessionID, userID)
+	if err != nil {
+		return fmt.Errorf("Error revoking session! Please try again")
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func RevokeAll(userID int, db *sql.DB) error {
+	_, err := db.Exec(`
+		UPDATE sessions
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND revoked_at IS NULL;
+		`, userID)
+	if err != nil {
+		return fmt.Errorf("Error revoking sessions! Please try again")
+	}
+	return nil
+}
+
+func revoke(transaction *sql.Tx, sessionID string) error {
+	_, err := transaction.Exec(`
+		UPDATE sessions
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE session_id = ? AND revoked_at IS NULL;
+		`, sessionID)
+	if err != nil {
+		return fmt.Errorf("Error revoking session! Please try again")
+	}
+	return nil
+}
+
+func insertRefreshToken(transaction *sql.Tx, sessionID, refreshToken string) error {
+	_, err := transaction.Exec(`
+		INSERT INTO refresh_tokens (session_id, token_hash, expires_at)
+		VALUES (?, ?, ?);
+		`, sessionID, token.Hash(refreshToken), time.Now().Add(RefreshTokenTTL))
+	return err
+}
+
+// truncate shortens s to at most limit characters, the unit VARCHAR lengths
+// are counted in, without splitting a multi-byte character. Invalid UTF-8
+// that a client may send is replaced first, since MySQL rejects it.
+func truncate(s string, limit int) string {
+	s = strings.ToValidUTF8(s, "\uFFFD")
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	return string([]rune(s)[:limit])
+}
@@ -0,0 +1,142 @@
+package users
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	lockouts "github.com/quyld17/E-Commerce-Website/entities/lockout"
+	roles "github.com/quyld17/E-Commerce-Website/entities/role"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrStaffAccount = errors.New("Staff accounts can not be deleted here! Please contact an administrator")
+
+// DeleteAccount closes a customer account after checking its password. Orders
+// are kept for accounting, so instead of deleting rows the personal data in
+// the account, its addresses and the addresses copied onto its orders is
+// overwritten. Only the province of an address is kept, for regional
+// reporting. Everything happens in one transaction. The avatar key is
+// returned so the caller can remove the stored files.
+func DeleteAccount(userID int, password string, db *sql.DB) (string, error) {
+	transaction, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer transaction.Rollback()
+
+	var email, hashedPassword string
+	var avatarKey sql.NullString
+	var roleID int
+	err = transaction.QueryRow(`
+		SELECT email, password, role_id, avatar_key
+		FROM users
+		WHERE user_id = ? AND deleted_at IS NULL
+		FOR UPDATE;
+		`, userID).Scan(&email, &hashedPassword, &roleID, &avatarKey)
+	if err != nil {
+		return "", fmt.Errorf("Error deleting account! Please try again")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)); err != nil {
+		return "", fmt.Errorf("Wrong password! Please try again")
+	}
+	if roleID != roles.CustomerRoleID {
+		return "", ErrStaffAccount
+	}
+
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`
+			UPDATE users
+			SET email = ?,
+				password = '',
+				full_name = NULL,
+				date_of_birth = NULL,
+				phone_number = NULL,
+				gender = NULL,
+				verified_at = NULL,
+				totp_secret = NULL,
+				totp_enabled_at = NULL,
+				totp_last_step = NULL,
+				avatar_key = NULL,
+				deleted_at = CURRENT_TIMESTAMP
+			WHERE user_id = ?;
+			`, []interface{}{fmt.Sprintf("deleted-%d@deleted.invalid", userID), userID}},
+		{`
+			UPDATE addresses
+			SET name = 'Deleted',
+				recipient_name = 'Deleted',
+				phone_number = '',
+				street = 'Deleted',
+				ward = '',
+				district = '',
+				postal_code = '',
+				is_default = 0
+			WHERE user_id = ?;
+			`, []interface{}{userID}},
+		{`
+			UPDATE ` + "`orders`" + `
+			SET address = 'Deleted',
+				shipping_recipient_name = 'Deleted',
+				shipping_phone_number = '',
+				shipping_street = 'Deleted',
+				shipping_ward = '',
+				shipping_district = '',
+				shipping_postal_code = ''
+			WHERE user_id = ?;
+			`, []interface{}{userID}},
+		{`
+			DELETE FROM cart_products
+			WHERE user_id = ?;
+			`, []interface{}{userID}},
+		{`
+			UPDATE sessions
+			SET ip_address = '',
+				user_agent = '',
+				revoked_at = COALESCE(revoked_at, CURRENT_TIMESTAMP)
+			WHERE user_id = ?;
+			`, []interface{}{userID}},
+		{`
+			UPDATE login_history
+			SET ip_address = '',
+				user_agent = ''
+			WHERE user_id = ?;
+			`, []interface{}{userID}},
+		{`
+			DELETE FROM recovery_codes
+			WHERE user_id = ?;
+			`, []interface{}{userID}},
+		{`
+			DELETE FROM password_resets
+			WHERE user_id = ?;
+			`, []interface{}{userID}},
+		{`
+			DELETE FROM email_changes
+			WHERE user_id = ?;
+			`, []interface{}{userID}},
+		{`
+			DELETE FROM customer_notes
+			WHERE user_id = ?;
+			`, []interface{}{userID}},
+		{`
+			DELETE FROM customer_tags
+			WHERE user_id = ?;
+			`, []interface{}{userID}},
+		{`
+			DELETE FROM login_failures
+			WHERE scope = ? AND identifier = ?;
+			`, []interface{}{lockouts.ScopeEmail, email}},
+	}
+	for _, statement := range statements {
+		if _, err := transaction.Exec(statement.query, statement.args...); err != nil {
+			return "", fmt.Errorf("Error deleting account! Please try again")
+		}
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return "", err
+	}
+	return avatarKey.String, nil
+}
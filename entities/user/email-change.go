@@ -0,0 +1,140 @@
+package users
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/quyld17/E-Commerce-Website/services/token"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const EmailChangeTTL = 24 * time.Hour
+
+var ErrInvalidEmailChangeToken = errors.New("Invalid or expired confirmation link! Please request a new one")
+var ErrEmailTaken = errors.New("This email is already used by another account! Please try again")
+
+// RequestEmailChange checks the account's password and issues a single-use
+// token that moves the account to newEmail once it is confirmed. The current
+// email is returned so it can be told about the request.
+func RequestEmailChange(userID int, password, newEmail string, db *sql.DB) (string, string, error) {
+	var email, hashedPassword string
+	err := db.QueryRow(`
+		SELECT email, password
+		FROM users
+		WHERE user_id = ?;
+		`, userID).Scan(&email, &hashedPassword)
+	if err != nil {
+		return "", "", fmt.Errorf("Error changing email! Please try again")
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)); err != nil {
+		return "", "", fmt.Errorf("Wrong password! Please try again")
+	}
+	if newEmail == email {
+		return "", "", fmt.Errorf("New email must be different from current email! Please try again")
+	}
+	if taken, err := isEmailTaken(newEmail, db); err != nil {
+		return "", "", err
+	} else if taken {
+		return "", "", ErrEmailTaken
+	}
+
+	changeToken, err := token.New(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO email_changes (user_id, new_email, token_hash, expires_at)
+		VALUES (?, ?, ?, ?);
+		`, userID, newEmail, token.Hash(changeToken), time.Now().Add(EmailChangeTTL))
+	if err != nil {
+		return "", "", fmt.Errorf("Error changing email! Please try again")
+	}
+	return changeToken, email, nil
+}
+
+// ConfirmEmailChange swaps the account's email using a confirmation token and
+// invalidates every other pending change of that account. Following the link
+// proves the new address is reachable, so it counts as verified. The new
+// email is returned.
+func ConfirmEmailChange(changeToken string, db *sql.DB) (string, error) {
+	transaction, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer transaction.Rollback()
+
+	var userID int
+	var newEmail string
+	var expiresAt time.Time
+	var usedAt sql.NullTime
+	err = transaction.QueryRow(`
+		SELECT user_id, new_email, expires_at, used_at
+		FROM email_changes
+		WHERE token_hash = ?
+		FOR UPDATE;
+		`, token.Hash(changeToken)).Scan(&userID, &newEmail, &expiresAt, &usedAt)
+	if err == sql.ErrNoRows {
+		return "", ErrInvalidEmailChangeToken
+	}
+	if err != nil {
+		return "", err
+	}
+	if usedAt.Valid || time.Now().After(expiresAt) {
+		return "", ErrInvalidEmailChangeToken
+	}
+
+	var taken bool
+	err = transaction.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1
+			FROM users
+			WHERE email = ?
+		);
+		`, newEmail).Scan(&taken)
+	if err != nil {
+		return "", err
+	}
+	if taken {
+		return "", ErrEmailTaken
+	}
+
+	_, err = transaction.Exec(`
+		UPDATE users
+		SET email = ?,
+			verified_at = CURRENT_TIMESTAMP
+		WHERE user_id = ?;
+		`, newEmail, userID)
+	if err != nil {
+		return "", fmt.Errorf("Error changing email! Please try again")
+	}
+
+	_, err = transaction.Exec(`
+		UPDATE email_changes
+		SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND used_at IS NULL;
+		`, userID)
+	if err != nil {
+		return "", fmt.Errorf("Error changing email! Please try again")
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return "", fmt.Errorf("Error changing email! Please try again")
+	}
+	return newEmail, nil
+}
+
+func isEmailTaken(email string, db *sql.DB) (bool, error) {
+	var taken bool
+	err := db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1
+			FROM users
+			WHERE email = ?
+		);
+		`, email).Scan(&taken)
+	return taken, err
+}
@@ -0,0 +1,124 @@
+package users
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/quyld17/E-Commerce-Website/services/token"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const PasswordResetTTL = time.Hour
+
+var ErrInvalidResetToken = errors.New("Invalid or expired reset link! Please request a new one")
+
+// CreatePasswordReset issues a single-use reset token for the account with the
+// given email. sql.ErrNoRows is returned when no such account exists.
+func CreatePasswordReset(email string, db *sql.DB) (string, error) {
+	var userID int
+	err := db.QueryRow(`
+		SELECT user_id
+		FROM users
+		WHERE email = ?;
+		`, email).Scan(&userID)
+	if err != nil {
+		return "", err
+	}
+
+	resetToken, err := token.New(32)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO password_resets (user_id, token_hash, expires_at)
+		VALUES (?, ?, ?);
+		`, userID, token.Hash(resetToken), time.Now().Add(PasswordResetTTL))
+	if err != nil {
+		return "", fmt.Errorf("Error requesting password reset! Please try again")
+	}
+	return resetToken, nil
+}
+
+// GetPasswordResetEmail returns the email of the account a reset token
+// belongs to, so the new password can be checked against it.
+func GetPasswordResetEmail(resetToken string, db *sql.DB) (string, error) {
+	var email string
+	var expiresAt time.Time
+	var usedAt sql.NullTime
+	err := db.QueryRow(`
+		SELECT u.email, pr.expires_at, pr.used_at
+		FROM password_resets pr
+		JOIN users u ON pr.user_id = u.user_id
+		WHERE pr.token_hash = ?;
+		`, token.Hash(resetToken)).Scan(&email, &expiresAt, &usedAt)
+	if err == sql.ErrNoRows {
+		return "", ErrInvalidResetToken
+	}
+	if err != nil {
+		return "", err
+	}
+	if usedAt.Valid || time.Now().After(expiresAt) {
+		return "", ErrInvalidResetToken
+	}
+	return email, nil
+}
+
+// ResetPassword sets a new password using a reset token and invalidates every
+// outstanding reset token of that account.
+func ResetPassword(resetToken, newPassword string, bcryptCost int, db *sql.DB) (int, error) {
+	transaction, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer transaction.Rollback()
+
+	var resetID, userID int
+	var expiresAt time.Time
+	var usedAt sql.NullTime
+	err = transaction.QueryRow(`
+		SELECT reset_id, user_id, expires_at, used_at
+		FROM password_resets
+		WHERE token_hash = ?
+		FOR UPDATE;
+		`, token.Hash(resetToken)).Scan(&resetID, &userID, &expiresAt, &usedAt)
+	if err == sql.ErrNoRows {
+		return 0, ErrInvalidResetToken
+	}
+	if err != nil {
+		return 0, err
+	}
+	if usedAt.Valid || time.Now().After(expiresAt) {
+		return 0, ErrInvalidResetToken
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcryptCost)
+	if err != nil {
+		return 0, fmt.Errorf("Error processing password")
+	}
+
+	_, err = transaction.Exec(`
+		UPDATE users
+		SET password = ?
+		WHERE user_id = ?;
+		`, string(hashedPassword), userID)
+	if err != nil {
+		return 0, fmt.Errorf("Error resetting password! Please try again")
+	}
+
+	_, err = transaction.Exec(`
+		UPDATE password_resets
+		SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND used_at IS NULL;
+		`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("Error resetting password! Please try again")
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return 0, fmt.Errorf("Error resetting password! Please try again")
+	}
+	return userID, nil
+}
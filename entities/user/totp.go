@@ -0,0 +1,234 @@
+package users
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/quyld17/E-Commerce-Website/services/token"
+	"github.com/quyld17/E-Commerce-Website/services/totp"
+)
+
+const recoveryCodeCount = 10
+
+var ErrInvalidTOTPCode = errors.New("Invalid authentication code! Please try again")
+var ErrTOTPNotEnabled = errors.New("Two-factor authentication is not enabled")
+
+func IsTOTPEnabled(userID int, db *sql.DB) (bool, error) {
+	var enabled bool
+	err := db.QueryRow(`
+		SELECT totp_enabled_at IS NOT NULL
+		FROM users
+		WHERE user_id = ?;
+		`, userID).Scan(&enabled)
+	if err != nil {
+		return false, err
+	}
+	return enabled, nil
+}
+
+// StartTOTPEnrollment stores a new pending secret. It only takes effect once
+// EnableTOTP confirms the user's authenticator produces matching codes.
+func StartTOTPEnrollment(userID int, db *sql.DB) (string, error) {
+	enabled, err := IsTOTPEnabled(userID, db)
+	if err != nil {
+		return "", err
+	}
+	if enabled {
+		return "", fmt.Errorf("Two-factor authentication is already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.Exec(`
+		UPDATE users
+		SET totp_secret = ?,
+			totp_last_step = NULL
+		WHERE user_id = ?;
+		`, secret, userID)
+	if err != nil {
+		return "", fmt.Errorf("Error enrolling two-factor authentication! Please try again")
+	}
+	return secret, nil
+}
+
+// EnableTOTP confirms a pending enrollment with a code from the authenticator
+// and returns a fresh set of recovery codes.
+func EnableTOTP(userID int, code string, db *sql.DB) ([]string, error) {
+	transaction, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer transaction.Rollback()
+
+	var secret sql.NullString
+	var enabledAt sql.NullTime
+	err = transaction.QueryRow(`
+		SELECT totp_secret, totp_enabled_at
+		FROM users
+		WHERE user_id = ?
+		FOR UPDATE;
+		`, userID).Scan(&secret, &enabledAt)
+	if err != nil {
+		return nil, err
+	}
+	if enabledAt.Valid {
+		return nil, fmt.Errorf("Two-factor authentication is already enabled")
+	}
+	if !secret.Valid {
+		return nil, fmt.Errorf("Two-factor authentication enrollment has not been started")
+	}
+
+	step, ok := totp.Validate(secret.String, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTOTPCode
+	}
+
+	_, err = transaction.Exec(`
+		UPDATE users
+		SET totp_enabled_at = CURRENT_TIMESTAMP,
+			totp_last_step = ?
+		WHERE user_id = ?;
+		`, step, userID)
+	if err != nil {
+		return nil, fmt.Errorf("Error enabling two-factor authentication! Please try again")
+	}
+
+	codes, err := replaceRecoveryCodes(transaction, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifyTOTP checks the second factor of a sign-in. Either a code from the
+// authenticator or an unused recovery code is accepted, and neither can be
+// replayed.
+func VerifyTOTP(userID int, code, recoveryCode string, db *sql.DB) error {
+	transaction, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer transaction.Rollback()
+
+	var secret sql.NullString
+	var lastStep sql.NullInt64
+	var enabledAt sql.NullTime
+	err = transaction.QueryRow(`
+		SELECT totp_secret, totp_last_step, totp_enabled_at
+		FROM users
+		WHERE user_id = ?
+		FOR UPDATE;
+		`, userID).Scan(&secret, &lastStep, &enabledAt)
+	if err != nil {
+		return err
+	}
+	if !enabledAt.Valid {
+		return ErrTOTPNotEnabled
+	}
+
+	if recoveryCode != "" {
+		result, err := transaction.Exec(`
+			UPDATE recovery_codes
+			SET used_at = CURRENT_TIMESTAMP
+			WHERE user_id = ? AND code_hash = ? AND used_at IS NULL;
+			`, userID, token.Hash(normalizeRecoveryCode(recoveryCode)))
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return ErrInvalidTOTPCode
+		}
+		return transaction.Commit()
+	}
+
+	step, ok := totp.Validate(secret.String, code, time.Now())
+	if !ok || (lastStep.Valid && step <= lastStep.Int64) {
+		return ErrInvalidTOTPCode
+	}
+
+	_, err = transaction.Exec(`
+		UPDATE users
+		SET totp_last_step = ?
+		WHERE user_id = ?;
+		`, step, userID)
+	if err != nil {
+		return err
+	}
+	return transaction.Commit()
+}
+
+// RegenerateRecoveryCodes replaces every recovery code of the user after
+// confirming a current authenticator code.
+func RegenerateRecoveryCodes(userID int, code string, db *sql.DB) ([]string, error) {
+	if err := VerifyTOTP(userID, code, "", db); err != nil {
+		return nil, err
+	}
+
+	transaction, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer transaction.Rollback()
+
+	codes, err := replaceRecoveryCodes(transaction, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func replaceRecoveryCodes(transaction *sql.Tx, userID int) ([]string, error) {
+	_, err := transaction.Exec(`
+		DELETE FROM recovery_codes
+		WHERE user_id = ?;
+		`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("Error generating recovery codes! Please try again")
+	}
+
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(buf))
+		code := raw[:4] + "-" + raw[4:]
+
+		_, err := transaction.Exec(`
+			INSERT INTO recovery_codes (user_id, code_hash)
+			VALUES (?, ?);
+			`, userID, token.Hash(normalizeRecoveryCode(code)))
+		if err != nil {
+			return nil, fmt.Errorf("Error generating recovery codes! Please try again")
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
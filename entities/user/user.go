@@ -2,7 +2,9 @@ package users
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -10,56 +12,117 @@ import (
 )
 
 type User struct {
-	UserId             int       `json:"user_id"`
-	Email              string    `json:"email"`
-	Password           string    `json:"password"`
-	NewPassword        string    `json:"new_password"`
-	FullName           string    `json:"full_name"`
-	DateOfBirth        time.Time `json:"date_of_birth"`
-	DateOfBirthDisplay string    `json:"date_of_birth_display"`
-	PhoneNumber        string    `json:"phone_number"`
-	Gender             int       `json:"gender"`
-	CreatedAt          time.Time `json:"created_at"`
-	CreatedAtDisplay   string    `json:"created_at_display"`
+	UserId             int               `json:"user_id"`
+	Email              string            `json:"email"`
+	Password           string            `json:"password"`
+	NewPassword        string            `json:"new_password"`
+	FullName           string            `json:"full_name"`
+	DateOfBirth        time.Time         `json:"date_of_birth"`
+	DateOfBirthDisplay string            `json:"date_of_birth_display"`
+	PhoneNumber        string            `json:"phone_number"`
+	Gender             int               `json:"gender"`
+	CreatedAt          time.Time         `json:"created_at"`
+	CreatedAtDisplay   string            `json:"created_at_display"`
+	Verified           bool              `json:"verified"`
+	Role               string            `json:"role,omitempty"`
+	AvatarKey          string            `json:"-"`
+	AvatarURLs         map[string]string `json:"avatar_urls,omitempty"`
+	Status             string            `json:"status,omitempty"`
 }
 
-func Authenticate(account User, db *sql.DB) error {
+const (
+	StatusActive   = "active"
+	StatusDisabled = "disabled"
+	StatusBanned   = "banned"
+)
+
+var ErrInvalidCredentials = errors.New("Invalid email or password! Please try again")
+var ErrAccountDisabled = errors.New("This account has been disabled! Please contact support")
+var ErrAccountBanned = errors.New("This account has been banned")
+
+// StatusError returns the error shown to a user whose account has the given
+// status, or nil when the account may be used.
+func StatusError(status string) error {
+	switch status {
+	case StatusActive:
+		return nil
+	case StatusBanned:
+		return ErrAccountBanned
+	default:
+		return ErrAccountDisabled
+	}
+}
+
+// Authenticate checks the password of an account. Hashes made with a lower
+// cost than bcryptCost are upgraded while the plain password is at hand.
+// Disabled and banned accounts are refused once the password is confirmed.
+func Authenticate(account User, bcryptCost int, db *sql.DB) (User, error) {
+	var user User
 	var hashedPassword []byte
 	err := db.QueryRow(`	
-		SELECT password 
-		FROM users
-		WHERE email = ?
-		`, account.Email).Scan(&hashedPassword)
+		SELECT u.user_id, u.email, u.password, r.role_name, u.status
+		FROM users u
+		JOIN roles r ON u.role_id = r.role_id
+		WHERE u.email = ?
+		`, account.Email).Scan(&user.UserId, &user.Email, &hashedPassword, &user.Role, &user.Status)
 
 	if err == sql.ErrNoRows {
-		return fmt.Errorf("Invalid email or password! Please try again")
+		return User{}, ErrInvalidCredentials
 	}
 	if err != nil {
-		return err
+		return User{}, err
 	}
 
 	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(account.Password))
 	if err != nil {
-		return fmt.Errorf("Invalid email or password! Please try again")
+		return User{}, ErrInvalidCredentials
+	}
+	if err := StatusError(user.Status); err != nil {
+		return User{}, err
 	}
 
-	return nil
+	if cost, err := bcrypt.Cost(hashedPassword); err == nil && cost < bcryptCost {
+		if err := rehash(user.UserId, account.Password, bcryptCost, db); err != nil {
+			log.Println("Failed to rehash password:", err)
+		}
+	}
+
+	return user, nil
+}
+
+func rehash(userID int, password string, bcryptCost int, db *sql.DB) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		UPDATE users
+		SET password = ?
+		WHERE user_id = ?;
+		`, string(hashedPassword), userID)
+	return err
 }
 
-func Create(newUser User, db *sql.DB) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newUser.Password), bcrypt.DefaultCost)
+func Create(newUser User, bcryptCost int, db *sql.DB) (int, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newUser.Password), bcryptCost)
 	if err != nil {
-		return fmt.Errorf("Error processing password")
+		return 0, fmt.Errorf("Error processing password")
 	}
 
-	_, err = db.Exec(`	
+	result, err := db.Exec(`	
 		INSERT INTO users (email, password) 
 		VALUES (?, ?)
 		`, newUser.Email, hashedPassword)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+
+	userID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(userID), nil
 }
 
 func GetDetails(userID int, db *sql.DB) (*User, error) {
@@ -69,7 +132,9 @@ func GetDetails(userID int, db *sql.DB) (*User, error) {
 			full_name,
 			phone_number,
 			gender,
-			date_of_birth
+			date_of_birth,
+			verified_at,
+			avatar_key
 		FROM users
 		WHERE user_id = ?;
 		`, userID)
@@ -79,12 +144,12 @@ func GetDetails(userID int, db *sql.DB) (*User, error) {
 
 	var user User
 	if row.Next() {
-		var nullFullName, nullPhoneNumber sql.NullString
+		var nullFullName, nullPhoneNumber, nullAvatarKey sql.NullString
 		var nullGender sql.NullInt64
-		var nullDateOfBirth sql.NullTime
+		var nullDateOfBirth, nullVerifiedAt sql.NullTime
 		var email string
 
-		err := row.Scan(&email, &nullFullName, &nullPhoneNumber, &nullGender, &nullDateOfBirth)
+		err := row.Scan(&email, &nullFullName, &nullPhoneNumber, &nullGender, &nullDateOfBirth, &nullVerifiedAt, &nullAvatarKey)
 		if err != nil {
 			return nil, err
 		}
@@ -93,6 +158,8 @@ func GetDetails(userID int, db *sql.DB) (*User, error) {
 		user.FullName = nullFullName.String
 		user.PhoneNumber = nullPhoneNumber.String
 		user.Gender = int(nullGender.Int64)
+		user.Verified = nullVerifiedAt.Valid
+		user.AvatarKey = nullAvatarKey.String
 
 		if nullDateOfBirth.Valid {
 			user.DateOfBirth = nullDateOfBirth.Time
@@ -104,21 +171,7 @@ func GetDetails(userID int, db *sql.DB) (*User, error) {
 	return &user, nil
 }
 
-func GetID(c echo.Context, db *sql.DB) (int, error) {
-	email := c.Get("email").(string)
-	row := db.QueryRow(`
-		SELECT user_id 
-		FROM users
-		WHERE email = ?;
-		`, email)
-	var userID int
-	if err := row.Scan(&userID); err != nil {
-		return 0, err
-	}
-	return userID, nil
-}
-
-func ChangePassword(userID int, password, newPassword string, c echo.Context, db *sql.DB) error {
+func ChangePassword(userID int, password, newPassword string, bcryptCost int, c echo.Context, db *sql.DB) error {
 	var hashedPassword string
 	err := db.QueryRow(`
 		SELECT password
@@ -132,7 +185,7 @@ func ChangePassword(userID int, password, newPassword string, c echo.Context, db
 		return fmt.Errorf("Wrong password! Please try again")
 	}
 
-	hashedNewPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	hashedNewPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcryptCost)
 	if err != nil {
 		return fmt.Errorf("Error while changing password! Please try again")
 	}
@@ -163,21 +216,88 @@ func UpdateDetails(userID int, fullName, phoneNumber string, gender int, dateOfB
 	return nil
 }
 
-func GetRole(email string, db *sql.DB) (string, error) {
-	var role string
+func VerifyEmail(userID int, email string, db *sql.DB) error {
+	result, err := db.Exec(`
+		UPDATE users
+		SET verified_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND email = ? AND verified_at IS NULL;
+		`, userID, email)
+	if err != nil {
+		return fmt.Errorf("Error verifying email! Please try again")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("Error verifying email! Please try again")
+	}
+	if affected == 0 {
+		return fmt.Errorf("Email is already verified or the link is no longer valid")
+	}
+	return nil
+}
+
+func IsVerified(userID int, db *sql.DB) (bool, error) {
+	var verified bool
 	err := db.QueryRow(`
-		SELECT role_name
-		FROM roles
-		WHERE role_id = (
-			SELECT role_id
-			FROM users
-			WHERE email = ?
-		)
-		`, email).Scan(&role)
+		SELECT verified_at IS NOT NULL
+		FROM users
+		WHERE user_id = ?;
+		`, userID).Scan(&verified)
+	if err != nil {
+		return false, err
+	}
+	return verified, nil
+}
+
+// SetAvatar points the account at a newly stored avatar, or clears it when
+// avatarKey is empty. The previous key is returned so its files can be
+// removed.
+func SetAvatar(userID int, avatarKey string, db *sql.DB) (string, error) {
+	transaction, err := db.Begin()
 	if err != nil {
 		return "", err
 	}
-	return role, nil
+	defer transaction.Rollback()
+
+	var previousKey sql.NullString
+	err = transaction.QueryRow(`
+		SELECT avatar_key
+		FROM users
+		WHERE user_id = ?
+		FOR UPDATE;
+		`, userID).Scan(&previousKey)
+	if err != nil {
+		return "", fmt.Errorf("Error updating avatar! Please try again")
+	}
+
+	_, err = transaction.Exec(`
+		UPDATE users
+		SET avatar_key = ?
+		WHERE user_id = ?;
+		`, sql.NullString{String: avatarKey, Valid: avatarKey != ""}, userID)
+	if err != nil {
+		return "", fmt.Errorf("Error updating avatar! Please try again")
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return "", fmt.Errorf("Error updating avatar! Please try again")
+	}
+	return previousKey.String, nil
+}
+
+// GetAccount returns the identity fields put into access tokens.
+func GetAccount(userID int, db *sql.DB) (User, error) {
+	var user User
+	err := db.QueryRow(`
+		SELECT u.user_id, u.email, r.role_name
+		FROM users u
+		JOIN roles r ON u.role_id = r.role_id
+		WHERE u.user_id = ?;
+		`, userID).Scan(&user.UserId, &user.Email, &user.Role)
+	if err != nil {
+		return User{}, err
+	}
+	return user, nil
 }
 
 func GetByPage(offset, limit int, search string, db *sql.DB) ([]User, error) {
@@ -194,7 +314,9 @@ func GetByPage(offset, limit int, search string, db *sql.DB) ([]User, error) {
 				date_of_birth,
 				phone_number,
 				gender,
-				created_at
+				created_at,
+				avatar_key,
+				status
 			FROM users
 			WHERE 
 				role_id = 1 AND
@@ -213,7 +335,9 @@ func GetByPage(offset, limit int, search string, db *sql.DB) ([]User, error) {
 				date_of_birth,
 				phone_number,
 				gender,
-				created_at
+				created_at,
+				avatar_key,
+				status
 			FROM users
 			WHERE role_id = 1
 			LIMIT ? OFFSET ?;
@@ -228,12 +352,14 @@ func GetByPage(offset, limit int, search string, db *sql.DB) ([]User, error) {
 	users := []User{}
 	for rows.Next() {
 		var user User
-		err := rows.Scan(&user.UserId, &user.Email, &user.FullName, &user.DateOfBirth, &user.PhoneNumber, &user.Gender, &user.CreatedAt)
+		var avatarKey sql.NullString
+		err := rows.Scan(&user.UserId, &user.Email, &user.FullName, &user.DateOfBirth, &user.PhoneNumber, &user.Gender, &user.CreatedAt, &avatarKey, &user.Status)
 		if err != nil {
 			return nil, err
 		}
+		user.AvatarKey = avatarKey.String
 		user.CreatedAtDisplay = user.CreatedAt.Format("2006-01-02 15:04:05")
-		user.DateOfBirthDisplay = user.DateOfBirth.Format("2006-01-02") 
+		user.DateOfBirthDisplay = user.DateOfBirth.Format("2006-01-02")
 		users = append(users, user)
 	}
 	err = rows.Err()
@@ -242,4 +368,4 @@ func GetByPage(offset, limit int, search string, db *sql.DB) ([]User, error) {
 	}
 
 	return users, nil
-}
\ No newline at end of file
+}
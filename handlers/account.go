@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	addresses "github.com/quyld17/E-Commerce-Website/entities/address"
+	"github.com/quyld17/E-Commerce-Website/entities/cart"
+	orders "github.com/quyld17/E-Commerce-Website/entities/order"
+	users "github.com/quyld17/E-Commerce-Website/entities/user"
+	"github.com/quyld17/E-Commerce-Website/middlewares"
+	"github.com/quyld17/E-Commerce-Website/services/storage"
+)
+
+// ExportAccount returns everything stored about the caller. The bundle is a
+// single JSON document, or a ZIP with one JSON file per section when
+// ?format=zip is given.
+func ExportAccount(c echo.Context, db *sql.DB) error {
+	userID := middlewares.GetPrincipal(c).UserID
+
+	profile, err := users.GetDetails(userID, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	savedAddresses, err := addresses.Get(userID, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	cartProducts, err := cart.GetProducts("", userID, c, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	placedOrders, err := orders.GetByPage(userID, c, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	sections := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile", profile},
+		{"addresses", savedAddresses},
+		{"cart", cartProducts},
+		{"orders", placedOrders},
+	}
+
+	switch c.QueryParam("format") {
+	case "", "json":
+		bundle := echo.Map{}
+		for _, section := range sections {
+			bundle[section.name] = section.data
+		}
+		c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="account-export.json"`)
+		return c.JSON(http.StatusOK, bundle)
+	case "zip":
+		var buffer bytes.Buffer
+		archive := zip.NewWriter(&buffer)
+		for _, section := range sections {
+			file, err := archive.Create(section.name + ".json")
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, err)
+			}
+			encoder := json.NewEncoder(file)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(section.data); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, err)
+			}
+		}
+		if err := archive.Close(); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err)
+		}
+		c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="account-export.zip"`)
+		return c.Blob(http.StatusOK, "application/zip", buffer.Bytes())
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid export format! Use json or zip")
+	}
+}
+
+func DeleteAccount(c echo.Context, db *sql.DB, store storage.Storage) error {
+	userID := middlewares.GetPrincipal(c).UserID
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if req.Password == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Please enter your password to delete your account")
+	}
+
+	avatarKey, err := users.DeleteAccount(userID, req.Password, db)
+	if errors.Is(err, users.ErrStaffAccount) {
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	deleteAvatarFiles(c, avatarKey, store)
+
+	return c.JSON(http.StatusOK, "Your account has been deleted")
+}
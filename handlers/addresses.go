@@ -7,21 +7,18 @@ import (
 
 	"github.com/labstack/echo/v4"
 	addresses "github.com/quyld17/E-Commerce-Website/entities/address"
-	users "github.com/quyld17/E-Commerce-Website/entities/user"
+	"github.com/quyld17/E-Commerce-Website/middlewares"
 )
 
 func AddAddress(c echo.Context, db *sql.DB) error {
-	userID, err := users.GetID(c, db)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err)
-	}
+	userID := middlewares.GetPrincipal(c).UserID
 
 	var address addresses.Address
 	if err := c.Bind(&address); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err)
 	}
 
-	if err := addresses.Add(userID, address.Name, address.Address, c, db); err != nil {
+	if err := addresses.Add(userID, address, c, db); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err)
 	}
 
@@ -29,10 +26,7 @@ func AddAddress(c echo.Context, db *sql.DB) error {
 }
 
 func GetAddresses(c echo.Context, db *sql.DB) error {
-	userID, err := users.GetID(c, db)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err)
-	}
+	userID := middlewares.GetPrincipal(c).UserID
 
 	addresses, err := addresses.Get(userID, db)
 	if err != nil {
@@ -43,10 +37,7 @@ func GetAddresses(c echo.Context, db *sql.DB) error {
 }
 
 func GetDefaultAddress(c echo.Context, db *sql.DB) error {
-	userID, err := users.GetID(c, db)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err)
-	}
+	userID := middlewares.GetPrincipal(c).UserID
 
 	address, err := addresses.GetDefault(userID, db)
 	if err != nil {
@@ -57,10 +48,7 @@ func GetDefaultAddress(c echo.Context, db *sql.DB) error {
 }
 
 func UpdateAddress(c echo.Context, db *sql.DB) error {
-	userID, err := users.GetID(c, db)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err)
-	}
+	userID := middlewares.GetPrincipal(c).UserID
 
 	addressID, err := strconv.Atoi(c.Param("addressID"))
 	if err != nil {
@@ -72,7 +60,7 @@ func UpdateAddress(c echo.Context, db *sql.DB) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err)
 	}
 
-	if err := addresses.Update(userID, addressID, address.Name, address.Address, c, db); err != nil {
+	if err := addresses.Update(userID, addressID, address, c, db); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err)
 	}
 
@@ -80,10 +68,7 @@ func UpdateAddress(c echo.Context, db *sql.DB) error {
 }
 
 func SetDefaultAddress(c echo.Context, db *sql.DB) error {
-	userID, err := users.GetID(c, db)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err)
-	}
+	userID := middlewares.GetPrincipal(c).UserID
 
 	addressID, err := strconv.Atoi(c.Param("addressID"))
 	if err != nil {
@@ -98,10 +83,7 @@ func SetDefaultAddress(c echo.Context, db *sql.DB) error {
 }
 
 func DeleteAddress(c echo.Context, db *sql.DB) error {
-	userID, err := users.GetID(c, db)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err)
-	}
+	userID := middlewares.GetPrincipal(c).UserID
 
 	addressID, err := strconv.Atoi(c.Param("addressID"))
 	if err != nil {
@@ -2,14 +2,17 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/labstack/echo/v4"
+	lockouts "github.com/quyld17/E-Commerce-Website/entities/lockout"
 	orders "github.com/quyld17/E-Commerce-Website/entities/order"
 	products "github.com/quyld17/E-Commerce-Website/entities/product"
 	users "github.com/quyld17/E-Commerce-Website/entities/user"
 	"github.com/quyld17/E-Commerce-Website/middlewares"
+	"github.com/quyld17/E-Commerce-Website/services/storage"
 )
 
 func GetOrdersByPage(c echo.Context, db *sql.DB) error {
@@ -30,7 +33,7 @@ func GetOrdersByPage(c echo.Context, db *sql.DB) error {
 }
 
 
-func GetCustomersByPage(c echo.Context, db *sql.DB) error {
+func GetCustomersByPage(c echo.Context, db *sql.DB, store storage.Storage) error {
 	itemsPerPage := 10
 	offset, err := middlewares.Pagination(c, itemsPerPage)
 	if err != nil {
@@ -43,6 +46,9 @@ func GetCustomersByPage(c echo.Context, db *sql.DB) error {
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err)
 	}
+	for i := range customers {
+		customers[i].AvatarURLs = avatarURLs(customers[i].AvatarKey, store)
+	}
 	return c.JSON(http.StatusOK, customers)
 }
 
@@ -57,8 +63,11 @@ func UpdateProduct(c echo.Context, db *sql.DB) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Missing or invalid required fields")
 	}
 
-	if err := products.Update(req, db); err != nil {
-		if err.Error()[:17] == "invalid size name:" {
+	if err := products.Update(req, middlewares.GetActor(c), db); err != nil {
+		if errors.Is(err, products.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		if errors.Is(err, products.ErrUnknownCategory) || errors.Is(err, products.ErrUnknownCollection) {
 			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update product")
@@ -78,7 +87,10 @@ func UpdateOrder(c echo.Context, db *sql.DB) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Missing or invalid required fields")
 	}
 
-	err := orders.Update(order.OrderID, order.Status, db)
+	err := orders.Update(order.OrderID, order.Status, middlewares.GetActor(c), db)
+	if errors.Is(err, orders.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update order")
 	}
@@ -99,3 +111,29 @@ func GetCustomerOrders(customerID string, c echo.Context, db *sql.DB) error {
 	return c.JSON(http.StatusOK, orders)
 }
 
+
+func GetLockoutsByPage(c echo.Context, db *sql.DB) error {
+	itemsPerPage := 10
+	offset, err := middlewares.Pagination(c, itemsPerPage)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	lockouts, err := lockouts.GetByPage(offset, itemsPerPage, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get lockouts")
+	}
+	return c.JSON(http.StatusOK, lockouts)
+}
+
+func ClearLockout(lockoutID string, c echo.Context, db *sql.DB) error {
+	id, err := strconv.Atoi(lockoutID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid lockout ID")
+	}
+
+	if err := lockouts.Clear(id, middlewares.GetActor(c), db); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	return c.JSON(http.StatusOK, "Lockout cleared successfully")
+}
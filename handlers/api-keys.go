@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	apikeys "github.com/quyld17/E-Commerce-Website/entities/apikey"
+	roles "github.com/quyld17/E-Commerce-Website/entities/role"
+	"github.com/quyld17/E-Commerce-Website/middlewares"
+)
+
+func GetAPIKeys(c echo.Context, db *sql.DB) error {
+	apiKeys, err := apikeys.GetAll(db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get API keys")
+	}
+	return c.JSON(http.StatusOK, apiKeys)
+}
+
+func CreateAPIKey(c echo.Context, db *sql.DB) error {
+	principal := middlewares.GetPrincipal(c)
+	if principal.APIKeyID != 0 {
+		return echo.NewHTTPError(http.StatusForbidden, "API keys can only be created by signed-in staff")
+	}
+
+	var req apikeys.APIKey
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request format")
+	}
+
+	// A key may only carry permissions its creator holds, so nobody can mint
+	// a key that is more powerful than themselves.
+	for _, scope := range req.Scopes {
+		allowed, err := roles.HasPermission(principal.Role, scope, db)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err)
+		}
+		if !allowed {
+			return echo.NewHTTPError(http.StatusForbidden, "You can not grant the "+scope+" scope")
+		}
+	}
+
+	apiKey, fullKey, err := apikeys.Create(req.Name, req.Scopes, middlewares.GetActor(c), db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"api_key": apiKey,
+		"key":     fullKey,
+	})
+}
+
+func RevokeAPIKey(keyID string, c echo.Context, db *sql.DB) error {
+	id, err := strconv.Atoi(keyID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid API key ID")
+	}
+
+	if err := apikeys.Revoke(id, middlewares.GetActor(c), db); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	return c.JSON(http.StatusOK, "API key revoked successfully")
+}
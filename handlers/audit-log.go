@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quyld17/E-Commerce-Website/entities/audit"
+	"github.com/quyld17/E-Commerce-Website/middlewares"
+)
+
+func GetAuditLog(c echo.Context, db *sql.DB) error {
+	itemsPerPage := 20
+	offset, err := middlewares.Pagination(c, itemsPerPage)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	filter := audit.Filter{
+		EntityType: c.QueryParam("entity_type"),
+		EntityID:   c.QueryParam("entity_id"),
+	}
+	if actorID := c.QueryParam("actor_id"); actorID != "" {
+		filter.ActorID, err = strconv.Atoi(actorID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid actor ID")
+		}
+	}
+	if filter.From, err = parseAuditTime(c.QueryParam("from"), false); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid from date")
+	}
+	if filter.To, err = parseAuditTime(c.QueryParam("to"), true); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid to date")
+	}
+
+	entries, err := audit.GetByPage(filter, offset, itemsPerPage, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get audit log")
+	}
+	return c.JSON(http.StatusOK, entries)
+}
+
+// parseAuditTime accepts either a full RFC 3339 timestamp or a plain date.
+// A plain date used as the end of a range includes that whole day.
+func parseAuditTime(value string, endOfRange bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfRange {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
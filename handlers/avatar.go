@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quyld17/E-Commerce-Website/config"
+	users "github.com/quyld17/E-Commerce-Website/entities/user"
+	"github.com/quyld17/E-Commerce-Website/middlewares"
+	"github.com/quyld17/E-Commerce-Website/services/imaging"
+	"github.com/quyld17/E-Commerce-Website/services/storage"
+	"github.com/quyld17/E-Commerce-Website/services/token"
+)
+
+// avatarSizes are the square thumbnails kept for every avatar, in pixels.
+var avatarSizes = []int{64, 128, 256}
+
+// UploadAvatar takes an image from the "avatar" form field and stores square
+// JPEG thumbnails of it. The upload is decoded and re-encoded, so its EXIF
+// data never reaches storage; only its orientation is applied to the pixels.
+func UploadAvatar(c echo.Context, db *sql.DB, cfg *config.Config, store storage.Storage) error {
+	userID := middlewares.GetPrincipal(c).UserID
+
+	fileHeader, err := c.FormFile("avatar")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Please choose an image to upload")
+	}
+	if fileHeader.Size > int64(cfg.Avatar.MaxBytes) {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("Image must not be larger than %d KB", cfg.Avatar.MaxBytes/1024))
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, int64(cfg.Avatar.MaxBytes)+1))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if len(data) > cfg.Avatar.MaxBytes {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("Image must not be larger than %d KB", cfg.Avatar.MaxBytes/1024))
+	}
+
+	img, err := imaging.Decode(data)
+	if errors.Is(err, imaging.ErrUnsupportedFormat) || errors.Is(err, imaging.ErrTooLarge) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	orientation := imaging.Orientation(data)
+
+	// Each upload gets a new key so browsers and CDNs never serve a stale
+	// picture from cache.
+	uploadID, err := token.NewID()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	avatarKey := "avatars/" + strconv.Itoa(userID) + "/" + uploadID
+
+	for _, size := range avatarSizes {
+		thumbnail, err := imaging.EncodeJPEG(imaging.Orient(imaging.SquareThumbnail(img, size), orientation))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err)
+		}
+		if err := store.Put(avatarFile(avatarKey, size), thumbnail); err != nil {
+			deleteAvatarFiles(c, avatarKey, store)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save avatar! Please try again")
+		}
+	}
+
+	previousKey, err := users.SetAvatar(userID, avatarKey, db)
+	if err != nil {
+		deleteAvatarFiles(c, avatarKey, store)
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	deleteAvatarFiles(c, previousKey, store)
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"avatar_urls": avatarURLs(avatarKey, store),
+	})
+}
+
+func DeleteAvatar(c echo.Context, db *sql.DB, store storage.Storage) error {
+	userID := middlewares.GetPrincipal(c).UserID
+
+	previousKey, err := users.SetAvatar(userID, "", db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	deleteAvatarFiles(c, previousKey, store)
+
+	return c.JSON(http.StatusOK, "Avatar removed successfully!")
+}
+
+// avatarURLs maps each thumbnail size to its public URL, or returns nil when
+// there is no avatar.
+func avatarURLs(avatarKey string, store storage.Storage) map[string]string {
+	if avatarKey == "" {
+		return nil
+	}
+	urls := map[string]string{}
+	for _, size := range avatarSizes {
+		urls[strconv.Itoa(size)] = store.URL(avatarFile(avatarKey, size))
+	}
+	return urls
+}
+
+func avatarFile(avatarKey string, size int) string {
+	return avatarKey + "-" + strconv.Itoa(size) + ".jpg"
+}
+
+// deleteAvatarFiles removes the thumbnails of an avatar that is no longer
+// used. Failures only leave orphaned files behind, so they are logged.
+func deleteAvatarFiles(c echo.Context, avatarKey string, store storage.Storage) {
+	if avatarKey == "" {
+		return
+	}
+	for _, size := range avatarSizes {
+		if err := store.Delete(avatarFile(avatarKey, size)); err != nil {
+			c.Logger().Error(err)
+		}
+	}
+}
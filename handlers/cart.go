@@ -8,14 +8,11 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/quyld17/E-Commerce-Website/entities/cart"
 	products "github.com/quyld17/E-Commerce-Website/entities/product"
-	users "github.com/quyld17/E-Commerce-Website/entities/user"
+	"github.com/quyld17/E-Commerce-Website/middlewares"
 )
 
 func GetCartProducts(c echo.Context, db *sql.DB, selected string) error {
-	userID, err := users.GetID(c, db)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err)
-	}
+	userID := middlewares.GetPrincipal(c).UserID
 
 	products, err := cart.GetProducts(selected, userID, c, db)
 	if err != nil {
@@ -36,10 +33,7 @@ func GetCartProducts(c echo.Context, db *sql.DB, selected string) error {
 }
 
 func AddProductToCart(c echo.Context, db *sql.DB) error {
-	userID, err := users.GetID(c, db)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err)
-	}
+	userID := middlewares.GetPrincipal(c).UserID
 
 	var product products.Product
 	if err := c.Bind(&product); err != nil {
@@ -54,10 +48,7 @@ func AddProductToCart(c echo.Context, db *sql.DB) error {
 }
 
 func UpdateCartProducts(c echo.Context, db *sql.DB) error {
-	userID, err := users.GetID(c, db)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err)
-	}
+	userID := middlewares.GetPrincipal(c).UserID
 
 	products := []products.Product{}
 	if err := c.Bind(&products); err != nil {
@@ -73,10 +64,7 @@ func UpdateCartProducts(c echo.Context, db *sql.DB) error {
 }
 
 func DeleteCartProduct(cartProductID string, c echo.Context, db *sql.DB) error {
-	userID, err := users.GetID(c, db)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err)
-	}
+	userID := middlewares.GetPrincipal(c).UserID
 
 	id, err := strconv.Atoi(cartProductID)
 	if err != nil {
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	categories "github.com/quyld17/E-Commerce-Website/entities/category"
+	products "github.com/quyld17/E-Commerce-Website/entities/product"
+	"github.com/quyld17/E-Commerce-Website/middlewares"
+)
+
+func GetCategories(c echo.Context, db *sql.DB) error {
+	tree, err := categories.GetTree(db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Unable to retrieve categories at the moment. Please try again")
+	}
+	return c.JSON(http.StatusOK, tree)
+}
+
+func GetCategoryProducts(slug string, c echo.Context, db *sql.DB) error {
+	itemsPerPage := 10
+	offset, err := middlewares.Pagination(c, itemsPerPage)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	category, err := categories.GetBySlug(slug, db)
+	if err == categories.ErrNotFound {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	categoryIDs, err := categories.GetSubtreeIDs(category.CategoryID, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	orderBy := productOrderBy(c.QueryParam("sort"), "products.product_id DESC")
+	products, numOfProds, err := products.GetByCategories(categoryIDs, itemsPerPage, offset, orderBy, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Unable to retrieve products at the moment. Please try again")
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"category":     category,
+		"products":     products,
+		"num_of_prods": numOfProds,
+	})
+}
+
+func CreateCategory(c echo.Context, db *sql.DB) error {
+	var category categories.Category
+	if err := c.Bind(&category); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request format")
+	}
+
+	category, err := categories.Create(category, middlewares.GetActor(c), db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, category)
+}
+
+func UpdateCategory(categoryID string, c echo.Context, db *sql.DB) error {
+	id, err := strconv.Atoi(categoryID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid category ID")
+	}
+
+	var category categories.Category
+	if err := c.Bind(&category); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request format")
+	}
+
+	err = categories.Update(id, category, middlewares.GetActor(c), db)
+	if err == categories.ErrNotFound {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, "Category updated successfully")
+}
+
+func DeleteCategory(categoryID string, c echo.Context, db *sql.DB) error {
+	id, err := strconv.Atoi(categoryID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid category ID")
+	}
+
+	err = categories.Delete(id, middlewares.GetActor(c), db)
+	if err == categories.ErrNotFound {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, "Category deleted successfully")
+}
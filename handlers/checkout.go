@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quyld17/E-Commerce-Website/config"
+	addresses "github.com/quyld17/E-Commerce-Website/entities/address"
+	"github.com/quyld17/E-Commerce-Website/entities/cart"
+	products "github.com/quyld17/E-Commerce-Website/entities/product"
+	"github.com/quyld17/E-Commerce-Website/middlewares"
+	"github.com/quyld17/E-Commerce-Website/services/shipping"
+)
+
+// checkout is what an order would contain if it were placed now.
+type checkout struct {
+	Products []products.Product
+	Subtotal int
+	Address  addresses.Address
+	Parcel   shipping.Parcel
+}
+
+// prepareCheckout gathers the selected cart products and the shipping
+// address. addressID 0 means the user's default address. Errors are returned
+// ready to be sent to the client.
+func prepareCheckout(userID, addressID int, c echo.Context, db *sql.DB, cfg *config.Config) (checkout, error) {
+	cartProducts, err := cart.GetProducts("true", userID, c, db)
+	if err != nil {
+		return checkout{}, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	result := checkout{Products: []products.Product{}}
+	for _, product := range cartProducts {
+		if !product.Selected {
+			continue
+		}
+		weight := product.WeightGrams
+		if weight <= 0 {
+			weight = cfg.Shipping.DefaultItemWeight
+		}
+		result.Products = append(result.Products, product)
+		result.Subtotal += product.Quantity * product.Price
+		result.Parcel.WeightGrams += product.Quantity * weight
+	}
+	if len(result.Products) == 0 {
+		return checkout{}, echo.NewHTTPError(http.StatusBadRequest, "Please select the products to order")
+	}
+
+	var savedAddress addresses.Address
+	if addressID != 0 {
+		savedAddress, err = addresses.GetByID(userID, addressID, db)
+	} else {
+		savedAddress, err = addresses.GetDefault(userID, db)
+	}
+	if err == addresses.ErrNotFound {
+		return checkout{}, echo.NewHTTPError(http.StatusBadRequest, "Please choose one of your saved addresses")
+	}
+	if err != nil {
+		return checkout{}, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	result.Address, err = addresses.Validate(savedAddress)
+	if err != nil {
+		return checkout{}, echo.NewHTTPError(http.StatusBadRequest, "Please complete this address before placing an order: "+err.Error())
+	}
+
+	result.Parcel.Province = result.Address.Province
+	result.Parcel.OrderValue = result.Subtotal
+	return result, nil
+}
+
+func GetShippingOptions(c echo.Context, db *sql.DB, cfg *config.Config) error {
+	userID := middlewares.GetPrincipal(c).UserID
+
+	// Quoting changes nothing, so it is a GET and stays available to support
+	// staff viewing as the customer. No address_id means the default address.
+	addressID := 0
+	if param := c.QueryParam("address_id"); param != "" {
+		id, err := strconv.Atoi(param)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err)
+		}
+		addressID = id
+	}
+
+	checkout, err := prepareCheckout(userID, addressID, c, db, cfg)
+	if err != nil {
+		return err
+	}
+	options, err := shipping.Quote(cfg.Shipping.OriginProvince, checkout.Parcel)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"address":        checkout.Address,
+		"subtotal_price": checkout.Subtotal,
+		"weight_grams":   checkout.Parcel.WeightGrams,
+		"options":        options,
+	})
+}
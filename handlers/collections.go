@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	collections "github.com/quyld17/E-Commerce-Website/entities/collection"
+	products "github.com/quyld17/E-Commerce-Website/entities/product"
+	"github.com/quyld17/E-Commerce-Website/middlewares"
+)
+
+func GetCollections(c echo.Context, db *sql.DB) error {
+	all, err := collections.GetAll(db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Unable to retrieve collections at the moment. Please try again")
+	}
+	return c.JSON(http.StatusOK, all)
+}
+
+func GetCollectionProducts(slug string, c echo.Context, db *sql.DB) error {
+	itemsPerPage := 10
+	offset, err := middlewares.Pagination(c, itemsPerPage)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	collection, err := collections.GetBySlug(slug, db)
+	if err == collections.ErrNotFound {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	// Without a sort option the curated order is kept.
+	orderBy := productOrderBy(c.QueryParam("sort"), "")
+	products, numOfProds, err := products.GetByCollection(collection.CollectionID, itemsPerPage, offset, orderBy, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Unable to retrieve products at the moment. Please try again")
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"collection":   collection,
+		"products":     products,
+		"num_of_prods": numOfProds,
+	})
+}
+
+func CreateCollection(c echo.Context, db *sql.DB) error {
+	var collection collections.Collection
+	if err := c.Bind(&collection); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request format")
+	}
+
+	collection, err := collections.Create(collection, middlewares.GetActor(c), db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, collection)
+}
+
+func UpdateCollection(collectionID string, c echo.Context, db *sql.DB) error {
+	id, err := strconv.Atoi(collectionID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid collection ID")
+	}
+
+	var collection collections.Collection
+	if err := c.Bind(&collection); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request format")
+	}
+
+	err = collections.Update(id, collection, middlewares.GetActor(c), db)
+	if err == collections.ErrNotFound {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, "Collection updated successfully")
+}
+
+func DeleteCollection(collectionID string, c echo.Context, db *sql.DB) error {
+	id, err := strconv.Atoi(collectionID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid collection ID")
+	}
+
+	err = collections.Delete(id, middlewares.GetActor(c), db)
+	if err == collections.ErrNotFound {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, "Collection deleted successfully")
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	addresses "github.com/quyld17/E-Commerce-Website/entities/address"
+	customers "github.com/quyld17/E-Commerce-Website/entities/customer"
+	"github.com/quyld17/E-Commerce-Website/middlewares"
+	"github.com/quyld17/E-Commerce-Website/services/storage"
+)
+
+func UpdateCustomerStatus(customerID string, c echo.Context, db *sql.DB) error {
+	id, err := strconv.Atoi(customerID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid customer ID")
+	}
+
+	var req struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request format")
+	}
+
+	if err := customers.SetStatus(id, req.Status, req.Reason, middlewares.GetActor(c), db); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, "Customer status updated successfully")
+}
+
+func GetCustomerNotes(customerID string, c echo.Context, db *sql.DB) error {
+	id, err := strconv.Atoi(customerID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid customer ID")
+	}
+
+	notes, err := customers.GetNotes(id, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get notes")
+	}
+	return c.JSON(http.StatusOK, notes)
+}
+
+func AddCustomerNote(customerID string, c echo.Context, db *sql.DB) error {
+	id, err := strconv.Atoi(customerID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid customer ID")
+	}
+
+	var req struct {
+		Body string `json:"body"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request format")
+	}
+
+	if err := customers.AddNote(id, req.Body, middlewares.GetActor(c), db); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, "Note added successfully")
+}
+
+func DeleteCustomerNote(customerID, noteID string, c echo.Context, db *sql.DB) error {
+	id, err := strconv.Atoi(customerID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid customer ID")
+	}
+	note, err := strconv.Atoi(noteID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid note ID")
+	}
+
+	if err := customers.DeleteNote(id, note, middlewares.GetActor(c), db); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	return c.JSON(http.StatusOK, "Note deleted successfully")
+}
+
+func UpdateCustomerTags(customerID string, c echo.Context, db *sql.DB) error {
+	id, err := strconv.Atoi(customerID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid customer ID")
+	}
+
+	var req struct {
+		Tags []string `json:"tags"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request format")
+	}
+
+	if err := customers.SetTags(id, req.Tags, middlewares.GetActor(c), db); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	tags, err := customers.GetTags(id, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get tags")
+	}
+	return c.JSON(http.StatusOK, tags)
+}
+
+// GetCustomer returns everything support needs about one customer: the
+// profile, saved addresses, order metrics, tags and internal notes.
+func GetCustomer(customerID string, c echo.Context, db *sql.DB, store storage.Storage) error {
+	id, err := strconv.Atoi(customerID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid customer ID")
+	}
+
+	profile, err := customers.GetProfile(id, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	profile.AvatarURLs = avatarURLs(profile.AvatarKey, store)
+
+	savedAddresses, err := addresses.Get(id, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	metrics, err := customers.GetMetrics(id, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get customer metrics")
+	}
+	tags, err := customers.GetTags(id, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get tags")
+	}
+	notes, err := customers.GetNotes(id, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get notes")
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"profile":   profile,
+		"addresses": savedAddresses,
+		"metrics":   metrics,
+		"tags":      tags,
+		"notes":     notes,
+	})
+}
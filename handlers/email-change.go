@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/mail"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quyld17/E-Commerce-Website/config"
+	users "github.com/quyld17/E-Commerce-Website/entities/user"
+	"github.com/quyld17/E-Commerce-Website/middlewares"
+	"github.com/quyld17/E-Commerce-Website/services/mailer"
+)
+
+func ChangeEmail(c echo.Context, db *sql.DB, cfg *config.Config, sender mailer.Mailer) error {
+	userID := middlewares.GetPrincipal(c).UserID
+
+	var req struct {
+		Password string `json:"password"`
+		NewEmail string `json:"new_email"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	if req.Password == "" || req.NewEmail == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "All fields must be filled! Please try again")
+	} else if len(req.NewEmail) > 255 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Input exceeds limit! Please try again")
+	}
+	if _, err := mail.ParseAddress(req.NewEmail); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid email address! Please try again")
+	}
+
+	changeToken, currentEmail, err := users.RequestEmailChange(userID, req.Password, req.NewEmail, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	link := cfg.AppURL + "/change-email?token=" + url.QueryEscape(changeToken)
+	err = sender.Send(mailer.Message{
+		To:      req.NewEmail,
+		Subject: "Confirm your new email address",
+		Body: "We received a request to use this address for your account.\r\n\r\n" +
+			"Open the link below within 24 hours to confirm the change:\r\n" +
+			link + "\r\n\r\n" +
+			"If you did not request this, you can ignore this email.\r\n",
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to send confirmation email! Please try again")
+	}
+
+	// The old address only gets a notice; the change itself can only be
+	// confirmed from the new one.
+	err = sender.Send(mailer.Message{
+		To:      currentEmail,
+		Subject: "Your email address is being changed",
+		Body: "Someone asked to change the email of your account to " + req.NewEmail + ".\r\n\r\n" +
+			"The change takes effect once it is confirmed from the new address. " +
+			"If this was not you, please change your password and sign out of all sessions.\r\n",
+	})
+	if err != nil {
+		c.Logger().Error(err)
+	}
+
+	return c.JSON(http.StatusOK, "A confirmation link has been sent to your new email address")
+}
+
+// ConfirmEmailChange completes a change of email. Access tokens identify the
+// user by ID, so existing sessions keep working and pick up the new email
+// the next time they are refreshed.
+func ConfirmEmailChange(c echo.Context, db *sql.DB) error {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if req.Token == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Confirmation token must not be empty! Please try again")
+	}
+
+	_, err := users.ConfirmEmailChange(req.Token, db)
+	if errors.Is(err, users.ErrInvalidEmailChangeToken) || errors.Is(err, users.ErrEmailTaken) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, "Email changed successfully!")
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quyld17/E-Commerce-Website/services/geo"
+)
+
+func GetProvinces(c echo.Context) error {
+	return c.JSON(http.StatusOK, geo.Provinces(c.QueryParam("q")))
+}
+
+func GetDistricts(provinceID string, c echo.Context) error {
+	provinceCode, err := strconv.Atoi(provinceID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid province ID")
+	}
+
+	districts, err := geo.Districts(provinceCode, c.QueryParam("q"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	return c.JSON(http.StatusOK, districts)
+}
+
+func GetWards(districtID string, c echo.Context) error {
+	districtCode, err := strconv.Atoi(districtID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid district ID")
+	}
+
+	wards, err := geo.Wards(districtCode, c.QueryParam("q"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	return c.JSON(http.StatusOK, wards)
+}
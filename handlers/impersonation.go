@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quyld17/E-Commerce-Website/entities/audit"
+	roles "github.com/quyld17/E-Commerce-Website/entities/role"
+	users "github.com/quyld17/E-Commerce-Website/entities/user"
+	"github.com/quyld17/E-Commerce-Website/middlewares"
+	"github.com/quyld17/E-Commerce-Website/services/jwt"
+)
+
+// ImpersonateCustomer gives support staff a short-lived, read-only token to
+// see the shop as the customer does. Every impersonation is written to the
+// audit log together with the reason given.
+func ImpersonateCustomer(customerID string, c echo.Context, db *sql.DB) error {
+	principal := middlewares.GetPrincipal(c)
+	if principal.APIKeyID != 0 {
+		return echo.NewHTTPError(http.StatusForbidden, "Impersonation is only available to signed-in staff")
+	}
+
+	id, err := strconv.Atoi(customerID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid customer ID")
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request format")
+	}
+	if req.Reason == "" || len(req.Reason) > 255 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Please give a reason of at most 255 characters")
+	}
+
+	customer, err := users.GetAccount(id, db)
+	if err == sql.ErrNoRows {
+		return echo.NewHTTPError(http.StatusNotFound, "Customer not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	if customer.Role != roles.CustomerRoleName {
+		return echo.NewHTTPError(http.StatusForbidden, "Only customer accounts can be impersonated")
+	}
+
+	token, tokenID, expiresAt, err := jwt.GenerateImpersonation(customer, principal.UserID, principal.SessionID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	err = audit.Record(db, middlewares.GetActor(c), audit.Change{
+		Action:     audit.ActionImpersonationStart,
+		EntityType: audit.EntityUser,
+		EntityID:   strconv.Itoa(customer.UserId),
+		Details: echo.Map{
+			"reason":     req.Reason,
+			"token_id":   tokenID,
+			"expires_at": expiresAt.Format(time.RFC3339),
+		},
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"token":      token,
+		"expires_at": expiresAt,
+	})
+}
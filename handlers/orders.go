@@ -5,34 +5,45 @@ import (
 	"net/http"
 
 	"github.com/labstack/echo/v4"
-	"github.com/quyld17/E-Commerce-Website/entities/cart"
+	"github.com/quyld17/E-Commerce-Website/config"
 	orders "github.com/quyld17/E-Commerce-Website/entities/order"
 	users "github.com/quyld17/E-Commerce-Website/entities/user"
+	"github.com/quyld17/E-Commerce-Website/middlewares"
+	"github.com/quyld17/E-Commerce-Website/services/shipping"
 )
 
-func CreateOrder(c echo.Context, db *sql.DB) error {
-	userID, err := users.GetID(c, db)
+func CreateOrder(c echo.Context, db *sql.DB, cfg *config.Config) error {
+	userID := middlewares.GetPrincipal(c).UserID
+
+	verified, err := users.IsVerified(userID, db)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	if !verified {
+		return echo.NewHTTPError(http.StatusForbidden, "Please verify your email before placing an order")
 	}
 
+	// Only the payment method, address_id and shipping_method are read from
+	// the request. The address comes from the user's own address book and the
+	// shipping fee is priced again here.
 	var order orders.Order
 	if err := c.Bind(&order); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err)
 	}
 
-	orderedProducts, err := cart.GetProducts("true", userID, c, db)
+	checkout, err := prepareCheckout(userID, order.AddressID, c, db, cfg)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err)
+		return err
 	}
-	totalPrice := 0
-	for _, product := range orderedProducts {
-		if product.Selected {
-			totalPrice += product.Quantity * product.Price
-		}
+	shippingOption, err := shipping.Choose(cfg.Shipping.OriginProvince, checkout.Parcel, order.ShippingMethod)
+	if err == shipping.ErrUnknownMethod {
+		return echo.NewHTTPError(http.StatusBadRequest, "Please choose a shipping method")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	if err := orders.Create(orderedProducts, userID, totalPrice, order.PaymentMethod, order.Address, c, db); err != nil {
+	if err := orders.Create(checkout.Products, userID, checkout.Subtotal, order.PaymentMethod, checkout.Address, shippingOption, c, db); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err)
 	}
 
@@ -40,10 +51,7 @@ func CreateOrder(c echo.Context, db *sql.DB) error {
 }
 
 func GetOrders(c echo.Context, db *sql.DB) error {
-	userID, err := users.GetID(c, db)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err)
-	}
+	userID := middlewares.GetPrincipal(c).UserID
 
 	orders, err := orders.GetByPage(userID, c, db)
 	if err != nil {
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/mail"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quyld17/E-Commerce-Website/config"
+	sessions "github.com/quyld17/E-Commerce-Website/entities/session"
+	users "github.com/quyld17/E-Commerce-Website/entities/user"
+	"github.com/quyld17/E-Commerce-Website/middlewares"
+	"github.com/quyld17/E-Commerce-Website/services/mailer"
+)
+
+func ForgotPassword(c echo.Context, db *sql.DB, cfg *config.Config, sender mailer.Mailer) error {
+	var user users.User
+	if err := c.Bind(&user); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	if _, err := mail.ParseAddress(user.Email); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid email address! Please try again")
+	}
+
+	// The response is the same whether or not the account exists so the
+	// endpoint cannot be used to discover registered emails. The email is
+	// sent in the background for the same reason: waiting for the mail
+	// server, or failing when it is down, would only happen for real
+	// accounts.
+	response := "If an account with that email exists, a password reset link has been sent"
+
+	resetToken, err := users.CreatePasswordReset(user.Email, db)
+	if err == sql.ErrNoRows {
+		return c.JSON(http.StatusOK, response)
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	link := cfg.AppURL + "/reset-password?token=" + url.QueryEscape(resetToken)
+	message := mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: "We received a request to reset your password.\r\n\r\n" +
+			"Open the link below within the next hour to choose a new password:\r\n" +
+			link + "\r\n\r\n" +
+			"If you did not request this, you can ignore this email.\r\n",
+	}
+	logger := c.Logger()
+	go func() {
+		if err := sender.Send(message); err != nil {
+			logger.Error(err)
+		}
+	}()
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func ResetPassword(c echo.Context, db *sql.DB, cfg *config.Config) error {
+	var req struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	if req.Token == "" || req.NewPassword == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "All fields must be filled! Please try again")
+	} else if len(req.NewPassword) > 255 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Input exceeds limit! Please try again")
+	}
+
+	email, err := users.GetPasswordResetEmail(req.Token, db)
+	if errors.Is(err, users.ErrInvalidResetToken) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	if err := middlewares.ValidatePassword(req.NewPassword, email, cfg.Password); err != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	userID, err := users.ResetPassword(req.Token, req.NewPassword, cfg.Password.BcryptCost, db)
+	if errors.Is(err, users.ErrInvalidResetToken) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	if err := sessions.RevokeAll(userID, db); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, "Password reset successfully!")
+}
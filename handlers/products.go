@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -17,21 +18,7 @@ func GetProductsByPage(c echo.Context, db *sql.DB) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err)
 	}
 
-	sortParam := c.QueryParam("sort")
-	var orderBy string
-	switch sortParam {
-		case "price_desc":
-			orderBy = "products.price DESC"
-		case "price_asc":
-			orderBy = "products.price ASC"
-		case "name_desc":
-			orderBy = "products.product_name DESC"
-		case "name_asc":
-			orderBy = "products.product_name ASC"
-		default:
-			orderBy = "products.product_id DESC"
-	}
-
+	orderBy := productOrderBy(c.QueryParam("sort"), "products.product_id DESC")
 	searchParam := c.QueryParam("search")
 
 	products, numOfProds, err := products.GetByPage(c, db, itemsPerPage, offset, orderBy, searchParam)
@@ -45,6 +32,23 @@ func GetProductsByPage(c echo.Context, db *sql.DB) error {
 	})
 }
 
+// productOrderBy maps the sort options shared by every product listing to
+// SQL, using fallback when no known option is given.
+func productOrderBy(sortParam, fallback string) string {
+	switch sortParam {
+	case "price_desc":
+		return "products.price DESC"
+	case "price_asc":
+		return "products.price ASC"
+	case "name_desc":
+		return "products.product_name DESC"
+	case "name_asc":
+		return "products.product_name ASC"
+	default:
+		return fallback
+	}
+}
+
 func GetProduct(productID string, c echo.Context, db *sql.DB) error {
 	id, err := strconv.Atoi(productID)
 	if err != nil {
@@ -87,7 +91,10 @@ func DeleteProduct(productID string, c echo.Context, db *sql.DB) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err)
 	}
 
-	err = products.Delete(id, db)
+	err = products.Delete(id, middlewares.GetActor(c), db)
+	if errors.Is(err, products.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err)
 	}
@@ -105,7 +112,10 @@ func AddProduct(c echo.Context, db *sql.DB) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Missing or invalid required fields")
 	}
 	
-	err := products.Add(req, db)
+	err := products.Add(req, middlewares.GetActor(c), db)
+	if errors.Is(err, products.ErrUnknownCategory) || errors.Is(err, products.ErrUnknownCollection) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to add product")
 	}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	sessions "github.com/quyld17/E-Commerce-Website/entities/session"
+	users "github.com/quyld17/E-Commerce-Website/entities/user"
+	"github.com/quyld17/E-Commerce-Website/services/jwt"
+)
+
+func RefreshToken(c echo.Context, db *sql.DB) error {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if req.RefreshToken == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Refresh token must not be empty! Please try again")
+	}
+
+	session, refreshToken, err := sessions.Refresh(req.RefreshToken, db)
+	if errors.Is(err, sessions.ErrInvalidRefreshToken) || errors.Is(err, sessions.ErrRefreshTokenReused) || errors.Is(err, sessions.ErrSessionRevoked) {
+		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	user, err := users.GetAccount(session.UserID, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	token, err := jwt.Generate(user, session)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"token":         token,
+		"refresh_token": refreshToken,
+	})
+}
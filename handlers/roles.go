@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	roles "github.com/quyld17/E-Commerce-Website/entities/role"
+	sessions "github.com/quyld17/E-Commerce-Website/entities/session"
+	"github.com/quyld17/E-Commerce-Website/middlewares"
+)
+
+func GetRoles(c echo.Context, db *sql.DB) error {
+	roles, err := roles.GetAll(db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get roles")
+	}
+	return c.JSON(http.StatusOK, roles)
+}
+
+func GetPermissions(c echo.Context, db *sql.DB) error {
+	permissions, err := roles.GetPermissions(db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get permissions")
+	}
+	return c.JSON(http.StatusOK, permissions)
+}
+
+func CreateRole(c echo.Context, db *sql.DB) error {
+	var role roles.Role
+	if err := c.Bind(&role); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request format")
+	}
+
+	if err := roles.Create(role.RoleName, role.Permissions, middlewares.GetActor(c), db); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, "Role created successfully")
+}
+
+func UpdateRolePermissions(roleID string, c echo.Context, db *sql.DB) error {
+	id, err := strconv.Atoi(roleID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid role ID")
+	}
+
+	var role roles.Role
+	if err := c.Bind(&role); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request format")
+	}
+
+	if err := roles.SetPermissions(id, role.Permissions, middlewares.GetActor(c), db); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, "Role permissions updated successfully")
+}
+
+func GetStaff(c echo.Context, db *sql.DB) error {
+	staff, err := roles.GetStaff(db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get staff")
+	}
+	return c.JSON(http.StatusOK, staff)
+}
+
+func AssignRole(userID string, c echo.Context, db *sql.DB) error {
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+	if id == middlewares.GetPrincipal(c).UserID {
+		return echo.NewHTTPError(http.StatusBadRequest, "You can not change your own role")
+	}
+
+	var role roles.Role
+	if err := c.Bind(&role); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request format")
+	}
+
+	if err := roles.Assign(id, role.RoleID, middlewares.GetActor(c), db); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	// Access tokens carry the role, so existing sessions are ended to make
+	// the new role apply right away.
+	if err := sessions.RevokeAll(id, db); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, "Role assigned successfully")
+}
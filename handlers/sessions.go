@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	sessions "github.com/quyld17/E-Commerce-Website/entities/session"
+	"github.com/quyld17/E-Commerce-Website/middlewares"
+)
+
+func GetSessions(c echo.Context, db *sql.DB) error {
+	principal := middlewares.GetPrincipal(c)
+
+	activeSessions, err := sessions.GetActive(principal.UserID, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	for i := range activeSessions {
+		activeSessions[i].Current = activeSessions[i].SessionID == principal.SessionID
+	}
+
+	return c.JSON(http.StatusOK, activeSessions)
+}
+
+func RevokeSession(sessionID string, c echo.Context, db *sql.DB) error {
+	userID := middlewares.GetPrincipal(c).UserID
+
+	err := sessions.RevokeForUser(sessionID, userID, db)
+	if errors.Is(err, sessions.ErrSessionNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, "Session signed out successfully!")
+}
+
+func GetLoginHistory(c echo.Context, db *sql.DB) error {
+	userID := middlewares.GetPrincipal(c).UserID
+
+	itemsPerPage := 10
+	offset, err := middlewares.Pagination(c, itemsPerPage)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	logins, err := sessions.GetLoginHistory(userID, offset, itemsPerPage, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, logins)
+}
+
+func RevokeAllSessions(c echo.Context, db *sql.DB) error {
+	userID := middlewares.GetPrincipal(c).UserID
+
+	if err := sessions.RevokeAll(userID, db); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, "Signed out of all sessions successfully!")
+}
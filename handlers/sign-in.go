@@ -2,15 +2,24 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/quyld17/E-Commerce-Website/config"
+	lockouts "github.com/quyld17/E-Commerce-Website/entities/lockout"
+	sessions "github.com/quyld17/E-Commerce-Website/entities/session"
 	users "github.com/quyld17/E-Commerce-Website/entities/user"
 	"github.com/quyld17/E-Commerce-Website/middlewares"
 	"github.com/quyld17/E-Commerce-Website/services/jwt"
+	"github.com/quyld17/E-Commerce-Website/services/mailer"
 )
 
-func SignIn(c echo.Context, db *sql.DB) error {
+func SignIn(c echo.Context, db *sql.DB, cfg *config.Config, sender mailer.Mailer) error {
 	var account users.User
 	if err := c.Bind(&account); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err)
@@ -22,15 +31,146 @@ func SignIn(c echo.Context, db *sql.DB) error {
 		}
 	}
 
-	err := users.Authenticate(account, db)
+	ip := c.RealIP()
+	if err := checkLockout(c, account.Email, ip, db); err != nil {
+		return err
+	}
+
+	user, err := users.Authenticate(account, cfg.Password.BcryptCost, db)
+	if errors.Is(err, users.ErrInvalidCredentials) {
+		if err := lockouts.RecordFailure(account.Email, ip, cfg.Lockout, db); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err)
+		}
+		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	}
+	if errors.Is(err, users.ErrAccountDisabled) || errors.Is(err, users.ErrAccountBanned) {
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	if err := lockouts.RecordSuccess(account.Email, db); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	totpEnabled, err := users.IsTOTPEnabled(user.UserId, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	if totpEnabled {
+		challenge, err := jwt.GenerateMFAChallenge(user.UserId, user.Email)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err)
+		}
+		return c.JSON(http.StatusOK, echo.Map{
+			"mfa_required": true,
+			"mfa_token":    challenge,
+		})
+	}
+
+	return startSession(c, user, false, db, sender)
+}
+
+func SignInMFA(c echo.Context, db *sql.DB, cfg *config.Config, sender mailer.Mailer) error {
+	var req struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Authentication code must not be empty! Please try again")
+	}
+
+	userID, email, err := jwt.ParseMFAChallenge(req.MFAToken)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
 	}
 
-	token, err := jwt.Generate(account.Email, db)
+	ip := c.RealIP()
+	if err := checkLockout(c, email, ip, db); err != nil {
+		return err
+	}
+
+	err = users.VerifyTOTP(userID, req.Code, req.RecoveryCode, db)
+	if errors.Is(err, users.ErrInvalidTOTPCode) {
+		if err := lockouts.RecordFailure(email, ip, cfg.Lockout, db); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err)
+		}
+		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	}
+	// Two-factor was turned off after the challenge was issued, so the
+	// challenge no longer proves anything. The user signs in again.
+	if errors.Is(err, users.ErrTOTPNotEnabled) {
+		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	user, err := users.GetAccount(userID, db)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err)
 	}
 
-	return c.JSON(http.StatusOK, echo.Map{"token": token})
+	return startSession(c, user, true, db, sender)
+}
+
+// checkLockout refuses the attempt while the email or the IP is locked out,
+// before any password hash is compared.
+func checkLockout(c echo.Context, email, ip string, db *sql.DB) error {
+	remaining, err := lockouts.Check(email, ip, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	if remaining > 0 {
+		seconds := int(math.Ceil(remaining.Seconds()))
+		c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+		return echo.NewHTTPError(http.StatusTooManyRequests, fmt.Sprintf("Too many failed sign-in attempts! Please try again in %d seconds", seconds))
+	}
+	return nil
+}
+
+func startSession(c echo.Context, user users.User, mfaVerified bool, db *sql.DB, sender mailer.Mailer) error {
+	client := sessions.Client{
+		IPAddress: c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+	}
+	session, refreshToken, err := sessions.Create(user.UserId, mfaVerified, client, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	// The customer is already signed in at this point, so a mail failure is
+	// only logged.
+	if session.NewDevice {
+		if err := notifyNewSignIn(user.Email, session, sender); err != nil {
+			c.Logger().Error(err)
+		}
+	}
+
+	token, err := jwt.Generate(user, session)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"token":         token,
+		"refresh_token": refreshToken,
+	})
+}
+
+func notifyNewSignIn(email string, session sessions.Session, sender mailer.Mailer) error {
+	return sender.Send(mailer.Message{
+		To:      email,
+		Subject: "New sign-in to your account",
+		Body: "Your account was just signed in to from a new device or location.\r\n\r\n" +
+			"Time: " + time.Now().Format("2006-01-02 15:04:05") + "\r\n" +
+			"IP address: " + session.IPAddress + "\r\n" +
+			"Device: " + session.UserAgent + "\r\n\r\n" +
+			"If this was not you, please change your password and sign out of all sessions.\r\n",
+	})
 }
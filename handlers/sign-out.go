@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	sessions "github.com/quyld17/E-Commerce-Website/entities/session"
+	"github.com/quyld17/E-Commerce-Website/middlewares"
+)
+
+func SignOut(c echo.Context, db *sql.DB) error {
+	principal := middlewares.GetPrincipal(c)
+	if err := sessions.RevokeToken(principal.TokenID, principal.ExpiresAt, db); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	if err := sessions.Revoke(principal.SessionID, db); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, "Signed out successfully!")
+}
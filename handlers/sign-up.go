@@ -5,11 +5,13 @@ import (
 	"net/http"
 
 	"github.com/labstack/echo/v4"
+	"github.com/quyld17/E-Commerce-Website/config"
 	users "github.com/quyld17/E-Commerce-Website/entities/user"
 	"github.com/quyld17/E-Commerce-Website/middlewares"
+	"github.com/quyld17/E-Commerce-Website/services/mailer"
 )
 
-func SignUp(c echo.Context, db *sql.DB) error {
+func SignUp(c echo.Context, db *sql.DB, cfg *config.Config, sender mailer.Mailer) error {
 	var newUser users.User
 	if err := c.Bind(&newUser); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err)
@@ -18,10 +20,20 @@ func SignUp(c echo.Context, db *sql.DB) error {
 	if err := middlewares.ValidateEmailAndPassword(newUser); err != "" {
 		return echo.NewHTTPError(http.StatusBadRequest, err)
 	}
+	if err := middlewares.ValidatePassword(newUser.Password, newUser.Email, cfg.Password); err != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
 
-	if err := users.Create(newUser, db); err != nil {
+	userID, err := users.Create(newUser, cfg.Password.BcryptCost, db)
+	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Account already existed! Please try again")
 	}
 
-	return c.JSON(http.StatusOK, "Account created successfully!")
+	// The account exists at this point, so a mail failure is only logged; the
+	// customer can ask for a new link from the resend endpoint.
+	if err := sendVerificationEmail(userID, newUser.Email, cfg.AppURL, sender); err != nil {
+		c.Logger().Error(err)
+	}
+
+	return c.JSON(http.StatusOK, "Account created successfully! Please check your email to verify your account")
 }
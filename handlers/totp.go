@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quyld17/E-Commerce-Website/config"
+	users "github.com/quyld17/E-Commerce-Website/entities/user"
+	"github.com/quyld17/E-Commerce-Website/middlewares"
+	"github.com/quyld17/E-Commerce-Website/services/totp"
+)
+
+func EnrollTOTP(c echo.Context, db *sql.DB, cfg *config.Config) error {
+	userID := middlewares.GetPrincipal(c).UserID
+
+	secret, err := users.StartTOTPEnrollment(userID, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"secret":           secret,
+		"provisioning_uri": totp.ProvisioningURI(secret, middlewares.GetPrincipal(c).Email, cfg.TOTPIssuer),
+	})
+}
+
+func EnableTOTP(c echo.Context, db *sql.DB) error {
+	userID := middlewares.GetPrincipal(c).UserID
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	recoveryCodes, err := users.EnableTOTP(userID, req.Code, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+func RegenerateRecoveryCodes(c echo.Context, db *sql.DB) error {
+	userID := middlewares.GetPrincipal(c).UserID
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	recoveryCodes, err := users.RegenerateRecoveryCodes(userID, req.Code, db)
+	if errors.Is(err, users.ErrInvalidTOTPCode) || errors.Is(err, users.ErrTOTPNotEnabled) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"recovery_codes": recoveryCodes,
+	})
+}
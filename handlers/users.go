@@ -5,30 +5,30 @@ import (
 	"net/http"
 
 	"github.com/labstack/echo/v4"
+	"github.com/quyld17/E-Commerce-Website/config"
+	sessions "github.com/quyld17/E-Commerce-Website/entities/session"
 	users "github.com/quyld17/E-Commerce-Website/entities/user"
+	"github.com/quyld17/E-Commerce-Website/middlewares"
+	"github.com/quyld17/E-Commerce-Website/services/storage"
 )
 
-func GetUserDetails(c echo.Context, db *sql.DB) error {
-	userID, err := users.GetID(c, db)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err)
-	}
+func GetUserDetails(c echo.Context, db *sql.DB, store storage.Storage) error {
+	userID := middlewares.GetPrincipal(c).UserID
 
 	user, err := users.GetDetails(userID, db)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err)
 	}
+	user.AvatarURLs = avatarURLs(user.AvatarKey, store)
 
 	return c.JSON(http.StatusOK, echo.Map{
 		"user": user,
 	})
 }
 
-func UpdateUserPassword(c echo.Context, db *sql.DB) error {
-	userID, err := users.GetID(c, db)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err)
-	}
+func UpdateUserPassword(c echo.Context, db *sql.DB, cfg *config.Config) error {
+	principal := middlewares.GetPrincipal(c)
+	userID := principal.UserID
 
 	var user users.User
 	if err := c.Bind(&user); err != nil {
@@ -42,19 +42,23 @@ func UpdateUserPassword(c echo.Context, db *sql.DB) error {
 	} else if user.Password == user.NewPassword {
 		return echo.NewHTTPError(http.StatusBadRequest, "New password must be different from current password! Please try again")
 	}
+	if err := middlewares.ValidatePassword(user.NewPassword, principal.Email, cfg.Password); err != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
 
-	if err := users.ChangePassword(userID, user.Password, user.NewPassword, c, db); err != nil {
+	if err := users.ChangePassword(userID, user.Password, user.NewPassword, cfg.Password.BcryptCost, c, db); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err)
 	}
 
+	if err := sessions.RevokeAll(userID, db); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
 	return c.JSON(http.StatusOK, "Updated successfully!")
 }
 
 func UpdateUserDetails(c echo.Context, db *sql.DB) error {
-	userID, err := users.GetID(c, db)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err)
-	}
+	userID := middlewares.GetPrincipal(c).UserID
 
 	var user users.User
 	if err := c.Bind(&user); err != nil {
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+	"github.com/quyld17/E-Commerce-Website/config"
+	users "github.com/quyld17/E-Commerce-Website/entities/user"
+	"github.com/quyld17/E-Commerce-Website/middlewares"
+	"github.com/quyld17/E-Commerce-Website/services/jwt"
+	"github.com/quyld17/E-Commerce-Website/services/mailer"
+)
+
+func VerifyEmail(c echo.Context, db *sql.DB) error {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	userID, email, err := jwt.ParseEmailVerification(req.Token)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := users.VerifyEmail(userID, email, db); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, "Email verified successfully!")
+}
+
+func ResendVerificationEmail(c echo.Context, db *sql.DB, cfg *config.Config, sender mailer.Mailer) error {
+	userID := middlewares.GetPrincipal(c).UserID
+
+	user, err := users.GetDetails(userID, db)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	if user.Verified {
+		return echo.NewHTTPError(http.StatusBadRequest, "Email is already verified")
+	}
+
+	if err := sendVerificationEmail(userID, user.Email, cfg.AppURL, sender); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to send verification email! Please try again")
+	}
+
+	return c.JSON(http.StatusOK, "Verification email sent!")
+}
+
+func sendVerificationEmail(userID int, email, appURL string, sender mailer.Mailer) error {
+	verificationToken, err := jwt.GenerateEmailVerification(userID, email)
+	if err != nil {
+		return err
+	}
+
+	link := appURL + "/verify-email?token=" + url.QueryEscape(verificationToken)
+	return sender.Send(mailer.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: "Thanks for signing up!\r\n\r\n" +
+			"Please confirm your email address by opening the link below within 24 hours:\r\n" +
+			link + "\r\n",
+	})
+}
@@ -1,19 +1,59 @@
 package middlewares
 
 import (
+	"database/sql"
+	_ "embed"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/mail"
-	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
+	"github.com/quyld17/E-Commerce-Website/config"
+	apikeys "github.com/quyld17/E-Commerce-Website/entities/apikey"
+	"github.com/quyld17/E-Commerce-Website/entities/audit"
+	roles "github.com/quyld17/E-Commerce-Website/entities/role"
+	sessions "github.com/quyld17/E-Commerce-Website/entities/session"
 	users "github.com/quyld17/E-Commerce-Website/entities/user"
 	jwtHandler "github.com/quyld17/E-Commerce-Website/services/jwt"
 )
 
+// Principal is the caller identified by the access token. It is read from
+// the token alone, so handlers never need to look the user up again. Calls
+// made with an API key have APIKeyID set instead of a user. When staff view
+// the shop as a customer, UserID is the customer and ImpersonatorID is the
+// staff member.
+type Principal struct {
+	UserID         int
+	APIKeyID       int
+	ImpersonatorID int
+	Email          string
+	Role           string
+	SessionID      string
+	TokenID        string
+	MFAVerified    bool
+	ExpiresAt      time.Time
+}
+
+func GetPrincipal(c echo.Context) Principal {
+	principal, _ := c.Get("principal").(Principal)
+	return principal
+}
+
+// GetActor returns the caller as recorded in the audit log.
+func GetActor(c echo.Context) audit.Actor {
+	principal := GetPrincipal(c)
+	return audit.Actor{
+		UserID:    principal.UserID,
+		APIKeyID:  principal.APIKeyID,
+		IPAddress: c.RealIP(),
+	}
+}
+
 func ValidateEmailAndPassword(user users.User) string {
 	_, err := mail.ParseAddress(user.Email)
 	if err != nil {
@@ -27,67 +67,169 @@ func ValidateEmailAndPassword(user users.User) string {
 	return ""
 }
 
-func AdminAuthorize(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		err := godotenv.Load(".env")
-		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, err)
-		}
+//go:embed common-passwords.txt
+var commonPasswordList string
 
-		tokenString := jwtHandler.GetToken(c)
-		if tokenString == "" {
-			return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+var commonPasswords = func() map[string]bool {
+	passwords := map[string]bool{}
+	for _, password := range strings.Split(commonPasswordList, "\n") {
+		if password = strings.TrimSpace(password); password != "" {
+			passwords[password] = true
 		}
+	}
+	return passwords
+}()
+
+// ValidatePassword applies the password policy to a newly chosen password.
+func ValidatePassword(password, email string, policy config.Password) string {
+	if len(password) < policy.MinLength {
+		return fmt.Sprintf("Password must be at least %d characters long! Please try again", policy.MinLength)
+	}
+	if len(password) > 72 {
+		return "Password must not be longer than 72 characters! Please try again"
+	}
+	if strings.EqualFold(password, email) {
+		return "Password must not be the same as your email! Please try again"
+	}
+	if commonPasswords[strings.ToLower(password)] {
+		return "This password is too common! Please choose a different one"
+	}
+	return ""
+}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET_KEY")), nil
-		})
-		if err != nil || !token.Valid {
-			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired token")
+// RequirePermission only lets staff whose role grants permission through.
+// Staff must also have signed in with their second factor. Integrations may
+// send an API key in the X-API-Key header instead, whose scopes are checked
+// against the same permission names.
+func RequirePermission(db *sql.DB, permission string, next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if key := c.Request().Header.Get("X-API-Key"); key != "" {
+			apiKey, err := apikeys.Authenticate(key, db)
+			if errors.Is(err, apikeys.ErrInvalidAPIKey) {
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			}
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, err)
+			}
+			if !apiKey.HasScope(permission) {
+				return echo.NewHTTPError(http.StatusForbidden, "API key is missing the "+permission+" scope")
+			}
+
+			c.Set("principal", Principal{APIKeyID: apiKey.KeyID})
+			return next(c)
 		}
 
-		email := jwtHandler.GetClaims(token, "email")
-		if email == "" {
-			return echo.NewHTTPError(http.StatusBadRequest, "Invalid claims")
+		principal, err := authenticate(c, db)
+		if err != nil {
+			return err
+		}
+		if principal.ImpersonatorID != 0 {
+			return echo.NewHTTPError(http.StatusForbidden, "You do not have permission to perform this action")
 		}
 
-		role := jwtHandler.GetClaims(token, "role")
-		if role != "Admin" {
-			return echo.NewHTTPError(http.StatusUnauthorized, "Admin access required")
+		allowed, err := roles.HasPermission(principal.Role, permission, db)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err)
+		}
+		if !allowed {
+			return echo.NewHTTPError(http.StatusForbidden, "You do not have permission to perform this action")
+		}
+		if !principal.MFAVerified {
+			return echo.NewHTTPError(http.StatusForbidden, "Two-factor authentication required")
 		}
 
-		c.Set("email", email)
+		c.Set("principal", principal)
 		return next(c)
 	}
 }
 
-func JWTAuthorize(next echo.HandlerFunc) echo.HandlerFunc {
+// JWTAuthorize lets any signed-in user through. Impersonation tokens are
+// read-only: staff viewing as a customer can look but not change anything.
+func JWTAuthorize(db *sql.DB, next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		err := godotenv.Load(".env")
+		principal, err := authenticate(c, db)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, err)
+			return err
 		}
-
-		tokenString := jwtHandler.GetToken(c)
-		if tokenString == "" {
-			return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+		method := c.Request().Method
+		if principal.ImpersonatorID != 0 && method != http.MethodGet && method != http.MethodHead {
+			return echo.NewHTTPError(http.StatusForbidden, "This action is not allowed while viewing as a customer")
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET_KEY")), nil
-		})
-		if err != nil || !token.Valid {
-			return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+		c.Set("principal", principal)
+		return next(c)
+	}
+}
+
+// OwnerAuthorize is JWTAuthorize for routes only the account owner may use,
+// even to read: the data export, sessions and login history reveal more
+// about the customer than support needs to see.
+func OwnerAuthorize(db *sql.DB, next echo.HandlerFunc) echo.HandlerFunc {
+	return JWTAuthorize(db, func(c echo.Context) error {
+		if GetPrincipal(c).ImpersonatorID != 0 {
+			return echo.NewHTTPError(http.StatusForbidden, "This action is not allowed while viewing as a customer")
 		}
+		return next(c)
+	})
+}
+
+func authenticate(c echo.Context, db *sql.DB) (Principal, error) {
+	tokenString := jwtHandler.GetToken(c)
+	if tokenString == "" {
+		return Principal{}, echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+	}
+
+	token, err := jwtHandler.Parse(tokenString)
+	if err != nil || !token.Valid {
+		return Principal{}, echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return Principal{}, echo.NewHTTPError(http.StatusBadRequest, "Invalid claims")
+	}
+	subject, err := claims.GetSubject()
+	if err != nil {
+		return Principal{}, echo.NewHTTPError(http.StatusBadRequest, "Invalid claims")
+	}
+	userID, err := strconv.Atoi(subject)
+	if err != nil {
+		return Principal{}, echo.NewHTTPError(http.StatusBadRequest, "Invalid claims")
+	}
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return Principal{}, echo.NewHTTPError(http.StatusBadRequest, "Invalid claims")
+	}
 
-		email := jwtHandler.GetClaims(token, "email")
-		if email == "" {
-			return echo.NewHTTPError(http.StatusBadRequest, "Invalid claims")
+	principal := Principal{
+		UserID:      userID,
+		Email:       jwtHandler.GetClaims(token, "email"),
+		Role:        jwtHandler.GetClaims(token, "role"),
+		SessionID:   jwtHandler.GetClaims(token, "sid"),
+		TokenID:     jwtHandler.GetClaims(token, "jti"),
+		MFAVerified: claims["mfa"] == true,
+		ExpiresAt:   expiresAt.Time,
+	}
+	if actor, ok := claims["act"].(map[string]interface{}); ok {
+		actorSubject, _ := actor["sub"].(string)
+		principal.ImpersonatorID, err = strconv.Atoi(actorSubject)
+		if err != nil || principal.ImpersonatorID == 0 {
+			return Principal{}, echo.NewHTTPError(http.StatusBadRequest, "Invalid claims")
 		}
-		c.Set("email", email)
+	}
 
-		return next(c)
+	err = sessions.CheckActive(principal.SessionID, principal.TokenID, db)
+	if errors.Is(err, sessions.ErrSessionRevoked) {
+		return Principal{}, echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	}
+	if errors.Is(err, users.ErrAccountDisabled) || errors.Is(err, users.ErrAccountBanned) {
+		return Principal{}, echo.NewHTTPError(http.StatusForbidden, err.Error())
 	}
+	if err != nil {
+		return Principal{}, echo.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return principal, nil
 }
 
 func Pagination(c echo.Context, itemsPerPage int) (int, error) {
@@ -0,0 +1,52 @@
+package middlewares
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/quyld17/E-Commerce-Website/config"
+)
+
+func TestValidatePassword(t *testing.T) {
+	policy := config.Password{MinLength: 8}
+	email := "Customer@Example.com"
+
+	tests := []struct {
+		name     string
+		password string
+		policy   config.Password
+		want     string
+	}{
+		{"accepted", "correct horse battery", policy, ""},
+		{"one below the minimum", "S3cret!", policy, "at least 8 characters"},
+		{"exactly the minimum", "S3cret!x", policy, ""},
+		{"minimum from the policy", "S3cret!x", config.Password{MinLength: 12}, "at least 12 characters"},
+		{"bcrypt limit", strings.Repeat("a1B", 24), policy, ""},
+		{"over the bcrypt limit", strings.Repeat("a1B", 24) + "c", policy, "not be longer than 72"},
+		{"same as the email", "customer@example.com", policy, "same as your email"},
+		{"common password", "password", policy, "too common"},
+		{"common password in capitals", "QWERTY123", policy, "too common"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ValidatePassword(tt.password, email, tt.policy)
+			if tt.want == "" && got != "" {
+				t.Fatalf("ValidatePassword(%q) = %q; want it accepted", tt.password, got)
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("ValidatePassword(%q) = %q; want a message containing %q", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommonPasswordsLoaded(t *testing.T) {
+	if len(commonPasswords) < 100 {
+		t.Fatalf("only %d common passwords loaded", len(commonPasswords))
+	}
+	for password := range commonPasswords {
+		if password != strings.ToLower(password) || password != strings.TrimSpace(password) {
+			t.Errorf("%q would never match: the list must be lowercase and trimmed", password)
+		}
+	}
+}
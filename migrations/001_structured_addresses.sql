@@ -0,0 +1,47 @@
+-- Moves existing databases from free-text addresses to structured ones.
+-- ECW.sql already has the final schema; this is only for databases created
+-- before it.
+--
+-- The old free-text address becomes the street line. Recipient name and
+-- phone number are copied from the owning account where it has them. Ward,
+-- district and province can not be recovered reliably from free text, so
+-- they stay empty and the API refuses to save the address again until the
+-- customer fills them in.
+
+ALTER TABLE `addresses`
+  ADD COLUMN `recipient_name` VARCHAR(100) NOT NULL DEFAULT '' AFTER `name`,
+  ADD COLUMN `phone_number` VARCHAR(11) NOT NULL DEFAULT '' AFTER `recipient_name`,
+  ADD COLUMN `street` VARCHAR(255) NOT NULL DEFAULT '' AFTER `phone_number`,
+  ADD COLUMN `ward` VARCHAR(100) NOT NULL DEFAULT '' AFTER `street`,
+  ADD COLUMN `district` VARCHAR(100) NOT NULL DEFAULT '' AFTER `ward`,
+  ADD COLUMN `province` VARCHAR(100) NOT NULL DEFAULT '' AFTER `district`,
+  ADD COLUMN `postal_code` VARCHAR(6) NOT NULL DEFAULT '' AFTER `province`;
+
+UPDATE `addresses` AS a
+JOIN `users` AS u ON a.user_id = u.user_id
+SET a.street = a.address,
+    a.recipient_name = COALESCE(TRIM(u.full_name), ''),
+    a.phone_number = COALESCE(TRIM(u.phone_number), '');
+
+ALTER TABLE `addresses`
+  DROP COLUMN `address`,
+  ALTER COLUMN `recipient_name` DROP DEFAULT,
+  ALTER COLUMN `phone_number` DROP DEFAULT,
+  ALTER COLUMN `street` DROP DEFAULT,
+  ALTER COLUMN `ward` DROP DEFAULT,
+  ALTER COLUMN `district` DROP DEFAULT,
+  ALTER COLUMN `province` DROP DEFAULT;
+
+-- Past orders keep their one-line address and get it as the street of the
+-- snapshot, so labels for old orders still print something useful.
+ALTER TABLE `orders`
+  ADD COLUMN `shipping_recipient_name` VARCHAR(100) NOT NULL DEFAULT '' AFTER `address`,
+  ADD COLUMN `shipping_phone_number` VARCHAR(11) NOT NULL DEFAULT '' AFTER `shipping_recipient_name`,
+  ADD COLUMN `shipping_street` VARCHAR(255) NOT NULL DEFAULT '' AFTER `shipping_phone_number`,
+  ADD COLUMN `shipping_ward` VARCHAR(100) NOT NULL DEFAULT '' AFTER `shipping_street`,
+  ADD COLUMN `shipping_district` VARCHAR(100) NOT NULL DEFAULT '' AFTER `shipping_ward`,
+  ADD COLUMN `shipping_province` VARCHAR(100) NOT NULL DEFAULT '' AFTER `shipping_district`,
+  ADD COLUMN `shipping_postal_code` VARCHAR(6) NOT NULL DEFAULT '' AFTER `shipping_province`;
+
+UPDATE `orders`
+SET shipping_street = address;
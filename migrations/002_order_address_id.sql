@@ -0,0 +1,6 @@
+-- Orders remember which saved address they were placed with. The column is
+-- only a reference: the address may be edited or deleted later, and the
+-- shipping_* snapshot stays the source of truth for the order.
+
+ALTER TABLE `orders`
+  ADD COLUMN `address_id` INT AFTER `payment_method`;
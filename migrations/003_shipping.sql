@@ -0,0 +1,16 @@
+-- Adds product weights and a separate shipping line to orders. Existing
+-- orders had no shipping charge, so their subtotal is their total.
+
+ALTER TABLE `products`
+  ADD COLUMN `weight_grams` INT NOT NULL DEFAULT 0;
+
+ALTER TABLE `orders`
+  ADD COLUMN `subtotal_price` DECIMAL(12,0) NOT NULL DEFAULT 0 AFTER `user_id`,
+  ADD COLUMN `shipping_method` VARCHAR(20) NOT NULL DEFAULT '' AFTER `subtotal_price`,
+  ADD COLUMN `shipping_fee` DECIMAL(12,0) NOT NULL DEFAULT 0 AFTER `shipping_method`;
+
+UPDATE `orders`
+SET subtotal_price = total_price;
+
+ALTER TABLE `orders`
+  ALTER COLUMN `subtotal_price` DROP DEFAULT;
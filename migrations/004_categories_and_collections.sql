@@ -0,0 +1,39 @@
+-- Adds nested categories and curated collections of products.
+
+CREATE TABLE `categories` (
+  `category_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `parent_id` INT NULL,
+  `name` VARCHAR(255) NOT NULL,
+  `slug` VARCHAR(255) UNIQUE NOT NULL,
+  `position` INT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE `product_categories` (
+  `product_id` INT NOT NULL,
+  `category_id` INT NOT NULL,
+  PRIMARY KEY (`product_id`, `category_id`)
+);
+
+CREATE TABLE `collections` (
+  `collection_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `name` VARCHAR(255) NOT NULL,
+  `slug` VARCHAR(255) UNIQUE NOT NULL,
+  `description` VARCHAR(1000) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE `collection_products` (
+  `collection_id` INT NOT NULL,
+  `product_id` INT NOT NULL,
+  `position` INT NOT NULL,
+  PRIMARY KEY (`collection_id`, `product_id`)
+);
+
+ALTER TABLE `categories` ADD FOREIGN KEY (`parent_id`) REFERENCES `categories` (`category_id`);
+
+ALTER TABLE `product_categories` ADD FOREIGN KEY (`product_id`) REFERENCES `products` (`product_id`);
+
+ALTER TABLE `product_categories` ADD FOREIGN KEY (`category_id`) REFERENCES `categories` (`category_id`);
+
+ALTER TABLE `collection_products` ADD FOREIGN KEY (`collection_id`) REFERENCES `collections` (`collection_id`);
+
+ALTER TABLE `collection_products` ADD FOREIGN KEY (`product_id`) REFERENCES `products` (`product_id`);
@@ -0,0 +1,21 @@
+-- Adds sign-in sessions and the rotating refresh tokens issued under them.
+
+CREATE TABLE `sessions` (
+  `session_id` CHAR(32) PRIMARY KEY NOT NULL,
+  `user_id` INT NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP),
+  `revoked_at` TIMESTAMP NULL
+);
+
+CREATE TABLE `refresh_tokens` (
+  `token_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `session_id` CHAR(32) NOT NULL,
+  `token_hash` CHAR(64) UNIQUE NOT NULL,
+  `expires_at` TIMESTAMP NOT NULL,
+  `used_at` TIMESTAMP NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP)
+);
+
+ALTER TABLE `sessions` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`user_id`);
+
+ALTER TABLE `refresh_tokens` ADD FOREIGN KEY (`session_id`) REFERENCES `sessions` (`session_id`);
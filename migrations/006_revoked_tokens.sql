@@ -0,0 +1,7 @@
+-- Adds the denylist of signed-out access tokens. Rows can be purged once
+-- expires_at has passed.
+
+CREATE TABLE `revoked_tokens` (
+  `jti` CHAR(32) PRIMARY KEY NOT NULL,
+  `expires_at` TIMESTAMP NOT NULL
+);
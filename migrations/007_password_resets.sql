@@ -0,0 +1,12 @@
+-- Adds single-use password reset tokens.
+
+CREATE TABLE `password_resets` (
+  `reset_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `user_id` INT NOT NULL,
+  `token_hash` CHAR(64) UNIQUE NOT NULL,
+  `expires_at` TIMESTAMP NOT NULL,
+  `used_at` TIMESTAMP NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP)
+);
+
+ALTER TABLE `password_resets` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`user_id`);
@@ -0,0 +1,9 @@
+-- Adds email verification. Accounts created before verification existed
+-- are treated as verified from the day they signed up, so existing
+-- customers can keep placing orders.
+
+ALTER TABLE `users`
+  ADD COLUMN `verified_at` TIMESTAMP NULL AFTER `role_id`;
+
+UPDATE `users`
+SET verified_at = created_at;
@@ -0,0 +1,21 @@
+-- Adds TOTP two-factor authentication. totp_last_step remembers the last
+-- accepted code so it can not be replayed. Existing sessions are marked
+-- as not having passed two-factor, which only matters once a user turns
+-- it on.
+
+ALTER TABLE `users`
+  ADD COLUMN `totp_secret` VARCHAR(64) AFTER `verified_at`,
+  ADD COLUMN `totp_enabled_at` TIMESTAMP NULL AFTER `totp_secret`,
+  ADD COLUMN `totp_last_step` BIGINT AFTER `totp_enabled_at`;
+
+ALTER TABLE `sessions`
+  ADD COLUMN `mfa_verified` TINYINT NOT NULL DEFAULT 0 AFTER `user_id`;
+
+CREATE TABLE `recovery_codes` (
+  `code_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `user_id` INT NOT NULL,
+  `code_hash` CHAR(64) NOT NULL,
+  `used_at` TIMESTAMP NULL
+);
+
+ALTER TABLE `recovery_codes` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`user_id`);
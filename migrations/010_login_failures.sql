@@ -0,0 +1,12 @@
+-- Adds the failed sign-in counters behind the per-email and per-IP
+-- lockouts.
+
+CREATE TABLE `login_failures` (
+  `lockout_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `scope` VARCHAR(10) NOT NULL,
+  `identifier` VARCHAR(255) NOT NULL,
+  `failed_count` INT NOT NULL DEFAULT 0,
+  `locked_until` TIMESTAMP NULL,
+  `last_failed_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP),
+  UNIQUE (`scope`, `identifier`)
+);
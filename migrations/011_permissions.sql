@@ -0,0 +1,51 @@
+-- Replaces the hardcoded Admin check with permissions granted to roles.
+-- Customer (1) and Admin (2) already exist; the staff roles are new. Admin
+-- keeps full access by being granted every permission.
+
+INSERT IGNORE INTO `roles` (`role_id`, `role_name`) VALUES
+  (1, 'Customer'),
+  (2, 'Admin'),
+  (3, 'Warehouse'),
+  (4, 'Support'),
+  (5, 'Catalog Editor');
+
+CREATE TABLE `permissions` (
+  `permission_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `permission_name` VARCHAR(255) UNIQUE NOT NULL,
+  `description` VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE `role_permissions` (
+  `role_id` INT NOT NULL,
+  `permission_id` INT NOT NULL,
+  PRIMARY KEY (`role_id`, `permission_id`)
+);
+
+ALTER TABLE `role_permissions` ADD FOREIGN KEY (`role_id`) REFERENCES `roles` (`role_id`);
+
+ALTER TABLE `role_permissions` ADD FOREIGN KEY (`permission_id`) REFERENCES `permissions` (`permission_id`);
+
+INSERT INTO `permissions` (`permission_name`, `description`) VALUES
+  ('products:read', 'View products in the back office'),
+  ('products:write', 'Add and update products'),
+  ('products:delete', 'Delete products'),
+  ('orders:read', 'View all orders'),
+  ('orders:update', 'Change order statuses'),
+  ('customers:read', 'View customers and their orders'),
+  ('lockouts:manage', 'View and clear sign-in lockouts'),
+  ('roles:manage', 'Manage roles, permissions and staff assignments');
+
+INSERT INTO `role_permissions` (`role_id`, `permission_id`)
+SELECT 2, `permission_id` FROM `permissions`;
+
+INSERT INTO `role_permissions` (`role_id`, `permission_id`)
+SELECT 3, `permission_id` FROM `permissions`
+WHERE `permission_name` IN ('products:read', 'orders:read', 'orders:update');
+
+INSERT INTO `role_permissions` (`role_id`, `permission_id`)
+SELECT 4, `permission_id` FROM `permissions`
+WHERE `permission_name` IN ('orders:read', 'customers:read', 'lockouts:manage');
+
+INSERT INTO `role_permissions` (`role_id`, `permission_id`)
+SELECT 5, `permission_id` FROM `permissions`
+WHERE `permission_name` IN ('products:read', 'products:write', 'products:delete');
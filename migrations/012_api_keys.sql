@@ -0,0 +1,22 @@
+-- Adds API keys for admin integrations. Only a hash of each key is stored.
+
+CREATE TABLE `api_keys` (
+  `key_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `name` VARCHAR(255) NOT NULL,
+  `prefix` CHAR(8) NOT NULL,
+  `key_hash` CHAR(64) UNIQUE NOT NULL,
+  `scopes` VARCHAR(1024) NOT NULL,
+  `created_by` INT NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP),
+  `last_used_at` TIMESTAMP NULL,
+  `revoked_at` TIMESTAMP NULL
+);
+
+ALTER TABLE `api_keys` ADD FOREIGN KEY (`created_by`) REFERENCES `users` (`user_id`);
+
+INSERT INTO `permissions` (`permission_name`, `description`) VALUES
+  ('api_keys:manage', 'Create and revoke API keys for integrations');
+
+INSERT INTO `role_permissions` (`role_id`, `permission_id`)
+SELECT 2, `permission_id` FROM `permissions`
+WHERE `permission_name` = 'api_keys:manage';
@@ -0,0 +1,25 @@
+-- Tracks where sessions are used from and keeps a login history for the
+-- new-device alerts. Existing sessions have no recorded device, and their
+-- last use is taken to be when they were created.
+
+ALTER TABLE `sessions`
+  ADD COLUMN `ip_address` VARCHAR(45) NOT NULL DEFAULT '' AFTER `mfa_verified`,
+  ADD COLUMN `user_agent` VARCHAR(255) NOT NULL DEFAULT '' AFTER `ip_address`,
+  ADD COLUMN `last_used_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP) AFTER `created_at`;
+
+UPDATE `sessions`
+SET last_used_at = created_at;
+
+CREATE TABLE `login_history` (
+  `login_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `user_id` INT NOT NULL,
+  `session_id` CHAR(32) NOT NULL,
+  `ip_address` VARCHAR(45) NOT NULL DEFAULT '',
+  `user_agent` VARCHAR(255) NOT NULL DEFAULT '',
+  `new_device` TINYINT NOT NULL DEFAULT 0,
+  `created_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP)
+);
+
+ALTER TABLE `login_history` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`user_id`);
+
+ALTER TABLE `login_history` ADD FOREIGN KEY (`session_id`) REFERENCES `sessions` (`session_id`);
@@ -0,0 +1,5 @@
+-- Marks accounts deleted by their owner. The row stays, anonymized, so
+-- orders keep their customer.
+
+ALTER TABLE `users`
+  ADD COLUMN `deleted_at` TIMESTAMP NULL AFTER `created_at`;
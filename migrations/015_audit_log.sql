@@ -0,0 +1,26 @@
+-- Adds the audit log, first used for impersonation, and lets Support
+-- impersonate customers.
+
+CREATE TABLE `audit_log` (
+  `audit_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `actor_id` INT NULL,
+  `api_key_id` INT NULL,
+  `action` VARCHAR(100) NOT NULL,
+  `entity_type` VARCHAR(50) NOT NULL,
+  `entity_id` VARCHAR(64) NOT NULL,
+  `details` JSON NULL,
+  `ip_address` VARCHAR(45) NOT NULL DEFAULT '',
+  `created_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP)
+);
+
+ALTER TABLE `audit_log` ADD FOREIGN KEY (`actor_id`) REFERENCES `users` (`user_id`);
+
+ALTER TABLE `audit_log` ADD FOREIGN KEY (`api_key_id`) REFERENCES `api_keys` (`key_id`);
+
+INSERT INTO `permissions` (`permission_name`, `description`) VALUES
+  ('customers:impersonate', 'View the shop as a customer for support');
+
+INSERT INTO `role_permissions` (`role_id`, `permission_id`)
+SELECT `role_id`, `permission_id`
+FROM `roles`, `permissions`
+WHERE `role_id` IN (2, 4) AND `permission_name` = 'customers:impersonate';
@@ -0,0 +1,15 @@
+-- Records the before and after of every admin write in the audit log, and
+-- indexes it for the filters of the audit log endpoint.
+
+ALTER TABLE `audit_log`
+  ADD COLUMN `changes` JSON NULL AFTER `entity_id`,
+  ADD INDEX (`actor_id`, `created_at`),
+  ADD INDEX (`entity_type`, `entity_id`, `created_at`),
+  ADD INDEX (`created_at`);
+
+INSERT INTO `permissions` (`permission_name`, `description`) VALUES
+  ('audit_log:read', 'View the audit log of back office changes');
+
+INSERT INTO `role_permissions` (`role_id`, `permission_id`)
+SELECT 2, `permission_id` FROM `permissions`
+WHERE `permission_name` = 'audit_log:read';
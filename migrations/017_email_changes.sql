@@ -0,0 +1,13 @@
+-- Adds pending email changes, confirmed from the new address.
+
+CREATE TABLE `email_changes` (
+  `change_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `user_id` INT NOT NULL,
+  `new_email` VARCHAR(255) NOT NULL,
+  `token_hash` CHAR(64) UNIQUE NOT NULL,
+  `expires_at` TIMESTAMP NOT NULL,
+  `used_at` TIMESTAMP NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP)
+);
+
+ALTER TABLE `email_changes` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`user_id`);
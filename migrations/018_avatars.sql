@@ -0,0 +1,4 @@
+-- Adds avatars. The key points into avatar storage; NULL means no avatar.
+
+ALTER TABLE `users`
+  ADD COLUMN `avatar_key` VARCHAR(255) AFTER `totp_last_step`;
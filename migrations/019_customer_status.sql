@@ -0,0 +1,35 @@
+-- Lets staff disable or ban customers and keep notes and tags on them.
+-- Every existing account starts out active.
+
+ALTER TABLE `users`
+  ADD COLUMN `status` VARCHAR(20) NOT NULL DEFAULT 'active' AFTER `avatar_key`,
+  ADD COLUMN `status_reason` VARCHAR(255) AFTER `status`,
+  ADD COLUMN `status_changed_at` TIMESTAMP NULL AFTER `status_reason`;
+
+CREATE TABLE `customer_notes` (
+  `note_id` INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+  `user_id` INT NOT NULL,
+  `author_id` INT NULL,
+  `body` VARCHAR(2000) NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP)
+);
+
+CREATE TABLE `customer_tags` (
+  `user_id` INT NOT NULL,
+  `tag` VARCHAR(50) NOT NULL,
+  PRIMARY KEY (`user_id`, `tag`)
+);
+
+ALTER TABLE `customer_notes` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`user_id`);
+
+ALTER TABLE `customer_notes` ADD FOREIGN KEY (`author_id`) REFERENCES `users` (`user_id`);
+
+ALTER TABLE `customer_tags` ADD FOREIGN KEY (`user_id`) REFERENCES `users` (`user_id`);
+
+INSERT INTO `permissions` (`permission_name`, `description`) VALUES
+  ('customers:manage', 'Disable or ban customers and keep notes and tags on them');
+
+INSERT INTO `role_permissions` (`role_id`, `permission_id`)
+SELECT `role_id`, `permission_id`
+FROM `roles`, `permissions`
+WHERE `role_id` IN (2, 4) AND `permission_name` = 'customers:manage';
@@ -2,52 +2,137 @@ package routers
 
 import (
 	"database/sql"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/quyld17/E-Commerce-Website/config"
+	roles "github.com/quyld17/E-Commerce-Website/entities/role"
 	"github.com/quyld17/E-Commerce-Website/handlers"
 	"github.com/quyld17/E-Commerce-Website/middlewares"
+	"github.com/quyld17/E-Commerce-Website/services/mailer"
+	"github.com/quyld17/E-Commerce-Website/services/storage"
 )
 
-func RegisterAPIHandlers(router *echo.Echo, db *sql.DB) {
+func RegisterAPIHandlers(router *echo.Echo, db *sql.DB, cfg *config.Config, sender mailer.Mailer, store storage.Storage) {
 	// Authentication
 	router.POST("/sign-up", func(c echo.Context) error {
-		return handlers.SignUp(c, db)
+		return handlers.SignUp(c, db, cfg, sender)
 	})
 	router.POST("/sign-in", func(c echo.Context) error {
-		return handlers.SignIn(c, db)
+		return handlers.SignIn(c, db, cfg, sender)
 	})
+	router.POST("/sign-in/mfa", func(c echo.Context) error {
+		return handlers.SignInMFA(c, db, cfg, sender)
+	})
+	router.POST("/auth/refresh", func(c echo.Context) error {
+		return handlers.RefreshToken(c, db)
+	})
+	router.POST("/verify-email", func(c echo.Context) error {
+		return handlers.VerifyEmail(c, db)
+	})
+	router.POST("/verify-email/resend", middlewares.JWTAuthorize(db, func(c echo.Context) error {
+		return handlers.ResendVerificationEmail(c, db, cfg, sender)
+	}))
+	router.POST("/change-email", func(c echo.Context) error {
+		return handlers.ConfirmEmailChange(c, db)
+	})
+	router.POST("/password/forgot", func(c echo.Context) error {
+		return handlers.ForgotPassword(c, db, cfg, sender)
+	})
+	router.POST("/password/reset", func(c echo.Context) error {
+		return handlers.ResetPassword(c, db, cfg)
+	})
+	router.GET("/.well-known/jwks.json", func(c echo.Context) error {
+		return handlers.GetJWKS(c)
+	})
+	router.POST("/sign-out", middlewares.JWTAuthorize(db, func(c echo.Context) error {
+		return handlers.SignOut(c, db)
+	}))
 
 	// Users
-	router.GET("/users/me", middlewares.JWTAuthorize(func(c echo.Context) error {
-		return handlers.GetUserDetails(c, db)
+	router.GET("/users/me", middlewares.JWTAuthorize(db, func(c echo.Context) error {
+		return handlers.GetUserDetails(c, db, store)
 	}))
-	router.PUT("/users/password", middlewares.JWTAuthorize(func(c echo.Context) error {
-		return handlers.UpdateUserPassword(c, db)
+	// The body limit stops oversized uploads before they are buffered; the
+	// extra 64 KB leaves room for the multipart envelope.
+	router.PUT("/users/me/avatar", middlewares.JWTAuthorize(db, func(c echo.Context) error {
+		return handlers.UploadAvatar(c, db, cfg, store)
+	}), middleware.BodyLimit(strconv.Itoa(cfg.Avatar.MaxBytes+64<<10)))
+	router.DELETE("/users/me/avatar", middlewares.JWTAuthorize(db, func(c echo.Context) error {
+		return handlers.DeleteAvatar(c, db, store)
 	}))
-	router.PUT("/users/me", middlewares.JWTAuthorize(func(c echo.Context) error {
+	router.PUT("/users/password", middlewares.JWTAuthorize(db, func(c echo.Context) error {
+		return handlers.UpdateUserPassword(c, db, cfg)
+	}))
+	router.PUT("/users/me", middlewares.JWTAuthorize(db, func(c echo.Context) error {
 		return handlers.UpdateUserDetails(c, db)
 	}))
+	router.POST("/users/me/email", middlewares.JWTAuthorize(db, func(c echo.Context) error {
+		return handlers.ChangeEmail(c, db, cfg, sender)
+	}))
+	router.GET("/users/me/export", middlewares.OwnerAuthorize(db, func(c echo.Context) error {
+		return handlers.ExportAccount(c, db)
+	}))
+	router.DELETE("/users/me", middlewares.JWTAuthorize(db, func(c echo.Context) error {
+		return handlers.DeleteAccount(c, db, store)
+	}))
+	router.POST("/users/me/totp/enroll", middlewares.JWTAuthorize(db, func(c echo.Context) error {
+		return handlers.EnrollTOTP(c, db, cfg)
+	}))
+	router.POST("/users/me/totp/enable", middlewares.JWTAuthorize(db, func(c echo.Context) error {
+		return handlers.EnableTOTP(c, db)
+	}))
+	router.POST("/users/me/totp/recovery-codes", middlewares.JWTAuthorize(db, func(c echo.Context) error {
+		return handlers.RegenerateRecoveryCodes(c, db)
+	}))
+	router.GET("/users/me/sessions", middlewares.OwnerAuthorize(db, func(c echo.Context) error {
+		return handlers.GetSessions(c, db)
+	}))
+	router.DELETE("/users/me/sessions/:sessionID", middlewares.OwnerAuthorize(db, func(c echo.Context) error {
+		sessionID := c.Param("sessionID")
+		return handlers.RevokeSession(sessionID, c, db)
+	}))
+	router.GET("/users/me/login-history", middlewares.OwnerAuthorize(db, func(c echo.Context) error {
+		return handlers.GetLoginHistory(c, db)
+	}))
+	router.POST("/users/me/sessions/revoke-all", middlewares.OwnerAuthorize(db, func(c echo.Context) error {
+		return handlers.RevokeAllSessions(c, db)
+	}))
 
 	// Addresses
-	router.GET("/addresses", middlewares.JWTAuthorize(func(c echo.Context) error {
+	router.GET("/addresses", middlewares.JWTAuthorize(db, func(c echo.Context) error {
 		return handlers.GetAddresses(c, db)
 	}))
-	router.GET("/default-address", middlewares.JWTAuthorize(func(c echo.Context) error {
+	router.GET("/default-address", middlewares.JWTAuthorize(db, func(c echo.Context) error {
 		return handlers.GetDefaultAddress(c, db)
 	}))
-	router.POST("/addresses", middlewares.JWTAuthorize(func(c echo.Context) error {
+	router.POST("/addresses", middlewares.JWTAuthorize(db, func(c echo.Context) error {
 		return handlers.AddAddress(c, db)
 	}))
-	router.PUT("/addresses/:addressID", middlewares.JWTAuthorize(func(c echo.Context) error {
+	router.PUT("/addresses/:addressID", middlewares.JWTAuthorize(db, func(c echo.Context) error {
 		return handlers.UpdateAddress(c, db)
 	}))
-	router.PUT("/addresses/default/:addressID", middlewares.JWTAuthorize(func(c echo.Context) error {
+	router.PUT("/addresses/default/:addressID", middlewares.JWTAuthorize(db, func(c echo.Context) error {
 		return handlers.SetDefaultAddress(c, db)
 	}))
-	router.DELETE("/addresses/:addressID", middlewares.JWTAuthorize(func(c echo.Context) error {
+	router.DELETE("/addresses/:addressID", middlewares.JWTAuthorize(db, func(c echo.Context) error {
 		return handlers.DeleteAddress(c, db)
 	}))
 
+	// Geo
+	router.GET("/geo/provinces", func(c echo.Context) error {
+		return handlers.GetProvinces(c)
+	})
+	router.GET("/geo/provinces/:provinceID/districts", func(c echo.Context) error {
+		provinceID := c.Param("provinceID")
+		return handlers.GetDistricts(provinceID, c)
+	})
+	router.GET("/geo/districts/:districtID/wards", func(c echo.Context) error {
+		districtID := c.Param("districtID")
+		return handlers.GetWards(districtID, c)
+	})
+
 	// Products
 	router.GET("/products", func(c echo.Context) error {
 		return handlers.GetProductsByPage(c, db)
@@ -64,59 +149,175 @@ func RegisterAPIHandlers(router *echo.Echo, db *sql.DB) {
 		return handlers.CheckProductExists(productID, c, db)
 	})
 
+	// Categories and collections
+	router.GET("/categories", func(c echo.Context) error {
+		return handlers.GetCategories(c, db)
+	})
+	router.GET("/categories/:slug/products", func(c echo.Context) error {
+		slug := c.Param("slug")
+		return handlers.GetCategoryProducts(slug, c, db)
+	})
+	router.GET("/collections", func(c echo.Context) error {
+		return handlers.GetCollections(c, db)
+	})
+	router.GET("/collections/:slug/products", func(c echo.Context) error {
+		slug := c.Param("slug")
+		return handlers.GetCollectionProducts(slug, c, db)
+	})
+
 	// Cart
-	router.GET("/cart-products", middlewares.JWTAuthorize(func(c echo.Context) error {
+	router.GET("/cart-products", middlewares.JWTAuthorize(db, func(c echo.Context) error {
 		selected := c.QueryParam("selected")
 		return handlers.GetCartProducts(c, db, selected)
 	}))
-	router.POST("/cart-products", middlewares.JWTAuthorize(func(c echo.Context) error {
+	router.POST("/cart-products", middlewares.JWTAuthorize(db, func(c echo.Context) error {
 		return handlers.AddProductToCart(c, db)
 	}))
-	router.PUT("/cart-products", middlewares.JWTAuthorize(func(c echo.Context) error {
+	router.PUT("/cart-products", middlewares.JWTAuthorize(db, func(c echo.Context) error {
 		return handlers.UpdateCartProducts(c, db)
 	}))
-	router.DELETE("/cart-products/:cart_product_id", middlewares.JWTAuthorize(func(c echo.Context) error {
+	router.DELETE("/cart-products/:cart_product_id", middlewares.JWTAuthorize(db, func(c echo.Context) error {
 		cartProductID := c.Param("cart_product_id")
 		return handlers.DeleteCartProduct(cartProductID, c, db)
 	}))
 
 	// Orders
-	router.GET("/orders/me", middlewares.JWTAuthorize(func(c echo.Context) error {
+	router.GET("/orders/me", middlewares.JWTAuthorize(db, func(c echo.Context) error {
 		return handlers.GetOrders(c, db)
 	}))
-	router.POST("/orders", middlewares.JWTAuthorize(func(c echo.Context) error {
-		return handlers.CreateOrder(c, db)
+	router.POST("/orders", middlewares.JWTAuthorize(db, func(c echo.Context) error {
+		return handlers.CreateOrder(c, db, cfg)
+	}))
+
+	// Checkout
+	router.GET("/checkout/shipping-options", middlewares.JWTAuthorize(db, func(c echo.Context) error {
+		return handlers.GetShippingOptions(c, db, cfg)
 	}))
 
 
 	// Admin
-	router.GET("/admin/products", middlewares.AdminAuthorize(func(c echo.Context) error {
+	router.GET("/admin/products", middlewares.RequirePermission(db, roles.ProductsRead, func(c echo.Context) error {
 		return handlers.GetProductsByPage(c, db)
 	}))
-	router.DELETE("/admin/products/:productID", middlewares.AdminAuthorize(func(c echo.Context) error {
+	router.DELETE("/admin/products/:productID", middlewares.RequirePermission(db, roles.ProductsDelete, func(c echo.Context) error {
 		productID := c.Param("productID")
 		return handlers.DeleteProduct(productID, c, db)
 	}))
-	router.PUT("/admin/products", middlewares.AdminAuthorize(func(c echo.Context) error {
+	router.PUT("/admin/products", middlewares.RequirePermission(db, roles.ProductsWrite, func(c echo.Context) error {
 		return handlers.UpdateProduct(c, db)
 	}))
-	router.POST("/admin/products", middlewares.AdminAuthorize(func(c echo.Context) error {
+	router.POST("/admin/products", middlewares.RequirePermission(db, roles.ProductsWrite, func(c echo.Context) error {
 		return handlers.AddProduct(c, db)
 	}))
+	router.POST("/admin/categories", middlewares.RequirePermission(db, roles.ProductsWrite, func(c echo.Context) error {
+		return handlers.CreateCategory(c, db)
+	}))
+	router.PUT("/admin/categories/:categoryID", middlewares.RequirePermission(db, roles.ProductsWrite, func(c echo.Context) error {
+		categoryID := c.Param("categoryID")
+		return handlers.UpdateCategory(categoryID, c, db)
+	}))
+	router.DELETE("/admin/categories/:categoryID", middlewares.RequirePermission(db, roles.ProductsDelete, func(c echo.Context) error {
+		categoryID := c.Param("categoryID")
+		return handlers.DeleteCategory(categoryID, c, db)
+	}))
+	router.POST("/admin/collections", middlewares.RequirePermission(db, roles.ProductsWrite, func(c echo.Context) error {
+		return handlers.CreateCollection(c, db)
+	}))
+	router.PUT("/admin/collections/:collectionID", middlewares.RequirePermission(db, roles.ProductsWrite, func(c echo.Context) error {
+		collectionID := c.Param("collectionID")
+		return handlers.UpdateCollection(collectionID, c, db)
+	}))
+	router.DELETE("/admin/collections/:collectionID", middlewares.RequirePermission(db, roles.ProductsDelete, func(c echo.Context) error {
+		collectionID := c.Param("collectionID")
+		return handlers.DeleteCollection(collectionID, c, db)
+	}))
 
 
-	router.GET("/admin/orders", middlewares.AdminAuthorize(func(c echo.Context) error {
+	router.GET("/admin/orders", middlewares.RequirePermission(db, roles.OrdersRead, func(c echo.Context) error {
 		return handlers.GetOrdersByPage(c, db)
 	}))
-	router.PUT("/admin/orders", middlewares.AdminAuthorize(func(c echo.Context) error {
+	router.PUT("/admin/orders", middlewares.RequirePermission(db, roles.OrdersUpdate, func(c echo.Context) error {
 		return handlers.UpdateOrder(c, db)
 	}))
 
-	router.GET("/admin/customers", middlewares.AdminAuthorize(func(c echo.Context) error {
-		return handlers.GetCustomersByPage(c, db)
+	router.GET("/admin/customers", middlewares.RequirePermission(db, roles.CustomersRead, func(c echo.Context) error {
+		return handlers.GetCustomersByPage(c, db, store)
+	}))
+	router.GET("/admin/customers/:customerID", middlewares.RequirePermission(db, roles.CustomersRead, func(c echo.Context) error {
+		customerID := c.Param("customerID")
+		return handlers.GetCustomer(customerID, c, db, store)
 	}))
-	router.GET("/admin/customers/:customerID/orders", middlewares.AdminAuthorize(func(c echo.Context) error {
+	router.GET("/admin/customers/:customerID/orders", middlewares.RequirePermission(db, roles.CustomersRead, func(c echo.Context) error {
 		customerID := c.Param("customerID")
 		return handlers.GetCustomerOrders(customerID, c, db)
 	}))
+	router.PUT("/admin/customers/:customerID/status", middlewares.RequirePermission(db, roles.CustomersManage, func(c echo.Context) error {
+		customerID := c.Param("customerID")
+		return handlers.UpdateCustomerStatus(customerID, c, db)
+	}))
+	router.GET("/admin/customers/:customerID/notes", middlewares.RequirePermission(db, roles.CustomersRead, func(c echo.Context) error {
+		customerID := c.Param("customerID")
+		return handlers.GetCustomerNotes(customerID, c, db)
+	}))
+	router.POST("/admin/customers/:customerID/notes", middlewares.RequirePermission(db, roles.CustomersManage, func(c echo.Context) error {
+		customerID := c.Param("customerID")
+		return handlers.AddCustomerNote(customerID, c, db)
+	}))
+	router.DELETE("/admin/customers/:customerID/notes/:noteID", middlewares.RequirePermission(db, roles.CustomersManage, func(c echo.Context) error {
+		customerID := c.Param("customerID")
+		noteID := c.Param("noteID")
+		return handlers.DeleteCustomerNote(customerID, noteID, c, db)
+	}))
+	router.PUT("/admin/customers/:customerID/tags", middlewares.RequirePermission(db, roles.CustomersManage, func(c echo.Context) error {
+		customerID := c.Param("customerID")
+		return handlers.UpdateCustomerTags(customerID, c, db)
+	}))
+	router.POST("/admin/customers/:customerID/impersonate", middlewares.RequirePermission(db, roles.CustomersImpersonate, func(c echo.Context) error {
+		customerID := c.Param("customerID")
+		return handlers.ImpersonateCustomer(customerID, c, db)
+	}))
+
+	router.GET("/admin/lockouts", middlewares.RequirePermission(db, roles.LockoutsManage, func(c echo.Context) error {
+		return handlers.GetLockoutsByPage(c, db)
+	}))
+	router.DELETE("/admin/lockouts/:lockoutID", middlewares.RequirePermission(db, roles.LockoutsManage, func(c echo.Context) error {
+		lockoutID := c.Param("lockoutID")
+		return handlers.ClearLockout(lockoutID, c, db)
+	}))
+
+	router.GET("/admin/roles", middlewares.RequirePermission(db, roles.RolesManage, func(c echo.Context) error {
+		return handlers.GetRoles(c, db)
+	}))
+	router.GET("/admin/permissions", middlewares.RequirePermission(db, roles.RolesManage, func(c echo.Context) error {
+		return handlers.GetPermissions(c, db)
+	}))
+	router.POST("/admin/roles", middlewares.RequirePermission(db, roles.RolesManage, func(c echo.Context) error {
+		return handlers.CreateRole(c, db)
+	}))
+	router.PUT("/admin/roles/:roleID/permissions", middlewares.RequirePermission(db, roles.RolesManage, func(c echo.Context) error {
+		roleID := c.Param("roleID")
+		return handlers.UpdateRolePermissions(roleID, c, db)
+	}))
+	router.GET("/admin/staff", middlewares.RequirePermission(db, roles.RolesManage, func(c echo.Context) error {
+		return handlers.GetStaff(c, db)
+	}))
+	router.PUT("/admin/users/:userID/role", middlewares.RequirePermission(db, roles.RolesManage, func(c echo.Context) error {
+		userID := c.Param("userID")
+		return handlers.AssignRole(userID, c, db)
+	}))
+
+	router.GET("/admin/audit-log", middlewares.RequirePermission(db, roles.AuditLogRead, func(c echo.Context) error {
+		return handlers.GetAuditLog(c, db)
+	}))
+
+	router.GET("/admin/api-keys", middlewares.RequirePermission(db, roles.APIKeysManage, func(c echo.Context) error {
+		return handlers.GetAPIKeys(c, db)
+	}))
+	router.POST("/admin/api-keys", middlewares.RequirePermission(db, roles.APIKeysManage, func(c echo.Context) error {
+		return handlers.CreateAPIKey(c, db)
+	}))
+	router.DELETE("/admin/api-keys/:keyID", middlewares.RequirePermission(db, roles.APIKeysManage, func(c echo.Context) error {
+		keyID := c.Param("keyID")
+		return handlers.RevokeAPIKey(keyID, c, db)
+	}))
 }
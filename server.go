@@ -1,29 +1,82 @@
 package main
 
 import (
+	"log"
+	"net"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/quyld17/E-Commerce-Website/config"
+	sessions "github.com/quyld17/E-Commerce-Website/entities/session"
 	"github.com/quyld17/E-Commerce-Website/routers"
 	"github.com/quyld17/E-Commerce-Website/services/database"
+	"github.com/quyld17/E-Commerce-Website/services/jwt"
+	"github.com/quyld17/E-Commerce-Website/services/mailer"
+	"github.com/quyld17/E-Commerce-Website/services/storage"
 )
 
 func main() {
-	db := database.NewMySQL()
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db := database.NewMySQL(cfg.Database)
+	go sessions.PruneRevokedTokensEvery(time.Hour, db)
+
+	if err := jwt.LoadKeys(cfg.JWT); err != nil {
+		log.Fatal(err)
+	}
+
+	sender, err := mailer.New(cfg.Mailer)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	router := echo.New()
+	router.IPExtractor = ipExtractor(cfg.TrustedProxies)
 	router.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins:     []string{"*"},
+		AllowOrigins:     cfg.CORS.AllowOrigins,
 		AllowMethods:     []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete},
 		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization},
 		ExposeHeaders:    []string{echo.HeaderContentLength},
-		AllowCredentials: true,
-		MaxAge:           int(24 * time.Hour.Seconds()),
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           int(cfg.CORS.MaxAge.Seconds()),
 	}))
 
-	routers.RegisterAPIHandlers(router, db)
+	if strings.HasPrefix(cfg.Storage.BaseURL, "/") {
+		router.Static(cfg.Storage.BaseURL, cfg.Storage.Dir)
+	}
+
+	routers.RegisterAPIHandlers(router, db, cfg, sender, store)
+
+	router.Logger.Fatal(router.Start(":" + cfg.Port))
+}
 
-	router.Logger.Fatal(router.Start(":8080"))
+// ipExtractor decides what c.RealIP() returns, which keys the sign-in
+// lockouts and is stored with sessions and the audit log. X-Forwarded-For is
+// only believed when it was added by one of the trusted proxies; otherwise a
+// client could pick its own IP.
+func ipExtractor(trustedProxies []*net.IPNet) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, network := range trustedProxies {
+		options = append(options, echo.TrustIPRange(network))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
 }
@@ -3,34 +3,32 @@ package database
 import (
 	"database/sql"
 	"log"
-	"os"
 
 	"github.com/go-sql-driver/mysql"
-	"github.com/joho/godotenv"
+	"github.com/quyld17/E-Commerce-Website/config"
 )
 
-func NewMySQL() *sql.DB {
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatal("Failed to retrieve credentials. Please try again")
-	}
-
-	cfg := mysql.Config{
-		User:                 os.Getenv("DB_USER"),
-		Passwd:               os.Getenv("DB_PASSWORD"),
+func NewMySQL(cfg config.Database) *sql.DB {
+	mysqlConfig := mysql.Config{
+		User:                 cfg.User,
+		Passwd:               cfg.Password,
 		Net:                  "tcp",
-		Addr:                 os.Getenv("DB_HOST") + ":" + os.Getenv("DB_PORT"),
-		DBName:               os.Getenv("DB_NAME"),
+		Addr:                 cfg.Host + ":" + cfg.Port,
+		DBName:               cfg.Name,
 		AllowNativePasswords: true,
 		Params: map[string]string{
 			"parseTime": "true",
 		},
 	}
 
-	db, err := sql.Open("mysql", cfg.FormatDSN())
+	db, err := sql.Open("mysql", mysqlConfig.FormatDSN())
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	if err := db.Ping(); err != nil {
+		log.Fatal("Failed to connect to the database: ", err)
+	}
+
 	return db
 }
@@ -0,0 +1,93 @@
+//go:build ignore
+
+// gen.go downloads provinces, districts and wards from the open provinces API
+// and writes the trimmed result to vietnam.json. Run it with
+// `go generate ./services/geo`. Where the API is not reachable, download
+// the same URL elsewhere and pass the file with
+// `go run gen.go -source depth3.json`.
+//
+// The output is refused unless every province has districts and every
+// district has wards, since geo.Resolve rejects addresses it can not place.
+// The only exceptions are the island districts with no communes.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// wardless are the island districts that have no wards at all. Nobody
+// lives at an address there, so they are allowed through empty.
+var wardless = map[string]bool{
+	"Huyện Bạch Long Vĩ": true,
+	"Huyện Cồn Cỏ":       true,
+	"Huyện Hoàng Sa":     true,
+}
+
+type division struct {
+	Code      int        `json:"code"`
+	Name      string     `json:"name"`
+	Districts []division `json:"districts,omitempty"`
+	Wards     []division `json:"wards,omitempty"`
+}
+
+func main() {
+	source := flag.String("source", "https://provinces.open-api.vn/api/?depth=3", "URL or file to read the depth=3 JSON from")
+	flag.Parse()
+
+	body, err := open(*source)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer body.Close()
+
+	var provinces []division
+	if err := json.NewDecoder(body).Decode(&provinces); err != nil {
+		log.Fatal(err)
+	}
+	if len(provinces) == 0 {
+		log.Fatal("no provinces returned")
+	}
+	for _, province := range provinces {
+		if len(province.Districts) == 0 {
+			log.Fatalf("province %d %s has no districts; was depth=3 requested?", province.Code, province.Name)
+		}
+		for _, district := range province.Districts {
+			if len(district.Wards) == 0 && !wardless[district.Name] {
+				log.Fatalf("district %d %s has no wards; was depth=3 requested?", district.Code, district.Name)
+			}
+		}
+	}
+
+	out, err := json.Marshal(provinces)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("vietnam.json", append(out, '\n'), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func open(source string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.Open(source)
+	}
+
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s from %s", resp.Status, source)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,237 @@
+// Package geo holds Vietnam's provinces, districts and wards and matches
+// hand-typed names against them.
+//
+// vietnam.json is produced by gen.go from the open provinces API. Run
+// `go generate ./services/geo` to refresh it. Every level is required: an
+// address is only accepted when its ward, district and province are all
+// found in the file.
+package geo
+
+//go:generate go run gen.go
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/quyld17/E-Commerce-Website/services/text"
+)
+
+//go:embed vietnam.json
+var data []byte
+
+var (
+	ErrProvinceNotFound = errors.New("Province not found")
+	ErrDistrictNotFound = errors.New("District not found")
+	ErrWardNotFound     = errors.New("Ward not found")
+)
+
+type Ward struct {
+	Code int    `json:"code"`
+	Name string `json:"name"`
+}
+
+type District struct {
+	Code  int    `json:"code"`
+	Name  string `json:"name"`
+	Wards []Ward `json:"wards,omitempty"`
+}
+
+type Province struct {
+	Code      int        `json:"code"`
+	Name      string     `json:"name"`
+	Districts []District `json:"districts,omitempty"`
+}
+
+// Division is the flat view of any level returned by the lookup endpoints.
+type Division struct {
+	Code int    `json:"code"`
+	Name string `json:"name"`
+}
+
+// Location is a resolved address with the official name and code of each
+// level.
+type Location struct {
+	ProvinceCode int
+	Province     string
+	DistrictCode int
+	District     string
+	WardCode     int
+	Ward         string
+}
+
+var (
+	provinces []Province
+	districts = map[int]*District{}
+)
+
+// Common ways of writing a province that do not match its official name.
+var provinceAliases = map[string]int{
+	"hcm":     79,
+	"tphcm":   79,
+	"sai gon": 79,
+	"saigon":  79,
+	"hue":     46,
+}
+
+// Administrative prefixes, in folded form, that people often leave out.
+var prefixes = []string{
+	"thanh pho ",
+	"tp ",
+	"tinh ",
+	"quan ",
+	"huyen ",
+	"thi xa ",
+	"thi tran ",
+	"phuong ",
+	"xa ",
+}
+
+func init() {
+	if err := load(data); err != nil {
+		panic("geo: invalid embedded dataset: " + err.Error())
+	}
+}
+
+func load(data []byte) error {
+	var loaded []Province
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return err
+	}
+	index := map[int]*District{}
+	for i := range loaded {
+		for j := range loaded[i].Districts {
+			district := &loaded[i].Districts[j]
+			index[district.Code] = district
+		}
+	}
+	provinces, districts = loaded, index
+	return nil
+}
+
+func stripPrefix(folded string) string {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(folded, prefix) {
+			return strings.TrimPrefix(folded, prefix)
+		}
+	}
+	return folded
+}
+
+// find returns the index of the name matching query, preferring an exact
+// match over one that only agrees once prefixes are dropped, so "Phường 1"
+// is not mistaken for "Xã 1". It returns -1 when nothing matches.
+func find(count int, name func(int) string, query string) int {
+	for i := 0; i < count; i++ {
+		if text.Fold(name(i)) == query {
+			return i
+		}
+	}
+	for i := 0; i < count; i++ {
+		if stripPrefix(text.Fold(name(i))) == stripPrefix(query) {
+			return i
+		}
+	}
+	return -1
+}
+
+func hasPrefix(name, query string) bool {
+	folded := text.Fold(name)
+	return strings.HasPrefix(folded, query) || strings.HasPrefix(stripPrefix(folded), stripPrefix(query))
+}
+
+// Provinces returns the provinces whose name starts with query, ignoring
+// diacritics and administrative prefixes. An empty query returns them all.
+func Provinces(query string) []Division {
+	query = text.Fold(query)
+	result := []Division{}
+	for _, province := range provinces {
+		if hasPrefix(province.Name, query) {
+			result = append(result, Division{Code: province.Code, Name: province.Name})
+		}
+	}
+	return result
+}
+
+func Districts(provinceCode int, query string) ([]Division, error) {
+	province, ok := ProvinceByCode(provinceCode)
+	if !ok {
+		return nil, ErrProvinceNotFound
+	}
+	query = text.Fold(query)
+	result := []Division{}
+	for _, district := range province.Districts {
+		if hasPrefix(district.Name, query) {
+			result = append(result, Division{Code: district.Code, Name: district.Name})
+		}
+	}
+	return result, nil
+}
+
+func Wards(districtCode int, query string) ([]Division, error) {
+	district, ok := districts[districtCode]
+	if !ok {
+		return nil, ErrDistrictNotFound
+	}
+	query = text.Fold(query)
+	result := []Division{}
+	for _, ward := range district.Wards {
+		if hasPrefix(ward.Name, query) {
+			result = append(result, Division{Code: ward.Code, Name: ward.Name})
+		}
+	}
+	return result, nil
+}
+
+// FindProvince matches a hand-typed province name.
+func FindProvince(name string) (Province, bool) {
+	query := text.Fold(name)
+	if code, ok := provinceAliases[stripPrefix(query)]; ok {
+		return ProvinceByCode(code)
+	}
+	i := find(len(provinces), func(i int) string { return provinces[i].Name }, query)
+	if i < 0 {
+		return Province{}, false
+	}
+	return provinces[i], true
+}
+
+// Resolve checks that the ward lies in the district and the district in the
+// province, and returns the official spelling of each.
+func Resolve(provinceName, districtName, wardName string) (Location, error) {
+	province, ok := FindProvince(provinceName)
+	if !ok {
+		return Location{}, ErrProvinceNotFound
+	}
+
+	i := find(len(province.Districts), func(i int) string { return province.Districts[i].Name }, text.Fold(districtName))
+	if i < 0 {
+		return Location{}, ErrDistrictNotFound
+	}
+	district := province.Districts[i]
+
+	i = find(len(district.Wards), func(i int) string { return district.Wards[i].Name }, text.Fold(wardName))
+	if i < 0 {
+		return Location{}, ErrWardNotFound
+	}
+
+	return Location{
+		ProvinceCode: province.Code,
+		Province:     province.Name,
+		DistrictCode: district.Code,
+		District:     district.Name,
+		WardCode:     district.Wards[i].Code,
+		Ward:         district.Wards[i].Name,
+	}, nil
+}
+
+// ProvinceByCode looks a province up by its official code.
+func ProvinceByCode(code int) (Province, bool) {
+	for _, province := range provinces {
+		if province.Code == code {
+			return province, true
+		}
+	}
+	return Province{}, false
+}
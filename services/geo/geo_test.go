@@ -0,0 +1,170 @@
+package geo
+
+import (
+	"errors"
+	"testing"
+)
+
+// testData is a small three-level dataset, so the tests do not depend on
+// what vietnam.json currently holds.
+const testData = `[
+	{"code": 1, "name": "Thành phố Hà Nội", "districts": [
+		{"code": 1, "name": "Quận Ba Đình", "wards": [
+			{"code": 1, "name": "Phường Phúc Xá"},
+			{"code": 4, "name": "Phường Trúc Bạch"}
+		]},
+		{"code": 271, "name": "Huyện Ba Vì", "wards": [
+			{"code": 9619, "name": "Thị trấn Tây Đằng"}
+		]}
+	]},
+	{"code": 46, "name": "Tỉnh Thừa Thiên Huế"},
+	{"code": 79, "name": "Thành phố Hồ Chí Minh", "districts": [
+		{"code": 770, "name": "Quận 3", "wards": [
+			{"code": 27139, "name": "Phường 1"},
+			{"code": 27140, "name": "Xã 1"}
+		]},
+		{"code": 783, "name": "Huyện Củ Chi", "wards": []}
+	]}
+]`
+
+func useTestData(t *testing.T) {
+	t.Helper()
+	savedProvinces, savedDistricts := provinces, districts
+	t.Cleanup(func() { provinces, districts = savedProvinces, savedDistricts })
+	if err := load([]byte(testData)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// wardless mirrors the island districts gen.go lets through without wards.
+var wardless = map[string]bool{
+	"Huyện Bạch Long Vĩ": true,
+	"Huyện Cồn Cỏ":       true,
+	"Huyện Hoàng Sa":     true,
+}
+
+func TestEmbeddedDatasetLoads(t *testing.T) {
+	if len(provinces) != 63 {
+		t.Errorf("embedded dataset has %d provinces; want 63", len(provinces))
+	}
+	for _, province := range provinces {
+		if len(province.Districts) == 0 {
+			t.Errorf("province %d %s has no districts", province.Code, province.Name)
+		}
+		for _, district := range province.Districts {
+			if len(district.Wards) == 0 && !wardless[district.Name] {
+				t.Errorf("district %d %s has no wards", district.Code, district.Name)
+			}
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	useTestData(t)
+
+	tests := []struct {
+		name                     string
+		province, district, ward string
+		want                     Location
+		wantErr                  error
+	}{
+		{
+			name:     "official names",
+			province: "Thành phố Hà Nội", district: "Quận Ba Đình", ward: "Phường Phúc Xá",
+			want: Location{1, "Thành phố Hà Nội", 1, "Quận Ba Đình", 1, "Phường Phúc Xá"},
+		},
+		{
+			name:     "no diacritics, prefixes or capitals",
+			province: "ha noi", district: "ba dinh", ward: "truc bach",
+			want: Location{1, "Thành phố Hà Nội", 1, "Quận Ba Đình", 4, "Phường Trúc Bạch"},
+		},
+		{
+			name:     "punctuation and spacing",
+			province: "  Hà-Nội ", district: "Ba  Đình,", ward: "phuc-xa",
+			want: Location{1, "Thành phố Hà Nội", 1, "Quận Ba Đình", 1, "Phường Phúc Xá"},
+		},
+		{
+			name:     "district prefix spelled out",
+			province: "Hà Nội", district: "huyen ba vi", ward: "Tây Đằng",
+			want: Location{1, "Thành phố Hà Nội", 271, "Huyện Ba Vì", 9619, "Thị trấn Tây Đằng"},
+		},
+		{
+			name:     "province alias",
+			province: "TP.HCM", district: "quan 3", ward: "phuong 1",
+			want: Location{79, "Thành phố Hồ Chí Minh", 770, "Quận 3", 27139, "Phường 1"},
+		},
+		{
+			name:     "exact match wins over a prefix-stripped one",
+			province: "Hồ Chí Minh", district: "3", ward: "Xã 1",
+			want: Location{79, "Thành phố Hồ Chí Minh", 770, "Quận 3", 27140, "Xã 1"},
+		},
+		{
+			name:     "unknown province",
+			province: "Atlantis", district: "Quận 1", ward: "Phường 1",
+			wantErr: ErrProvinceNotFound,
+		},
+		{
+			name:     "district in another province",
+			province: "Hà Nội", district: "Quận 3", ward: "Phường 1",
+			wantErr: ErrDistrictNotFound,
+		},
+		{
+			name:     "ward in another district",
+			province: "Hà Nội", district: "Ba Vì", ward: "Phúc Xá",
+			wantErr: ErrWardNotFound,
+		},
+		{
+			name:     "province without districts is not a free pass",
+			province: "Huế", district: "Thành phố Huế", ward: "Phường Phú Hội",
+			wantErr: ErrDistrictNotFound,
+		},
+		{
+			name:     "district without wards is not a free pass",
+			province: "HCM", district: "Củ Chi", ward: "Thị trấn Củ Chi",
+			wantErr: ErrWardNotFound,
+		},
+		{
+			name:     "empty ward",
+			province: "Hà Nội", district: "Ba Đình", ward: "",
+			wantErr: ErrWardNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Resolve(tt.province, tt.district, tt.ward)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Resolve error = %v; want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve = %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookups(t *testing.T) {
+	useTestData(t)
+
+	if got := Provinces(""); len(got) != 3 {
+		t.Errorf("Provinces(\"\") returned %d provinces; want 3", len(got))
+	}
+	if got := Provinces("tp ho"); len(got) != 1 || got[0].Code != 79 {
+		t.Errorf("Provinces(\"tp ho\") = %+v; want only 79", got)
+	}
+
+	districts, err := Districts(1, "ba")
+	if err != nil || len(districts) != 2 {
+		t.Errorf("Districts(1, \"ba\") = %+v, %v; want both Ba Đình and Ba Vì", districts, err)
+	}
+	if _, err := Districts(2, ""); !errors.Is(err, ErrProvinceNotFound) {
+		t.Errorf("Districts of an unknown province: got %v; want %v", err, ErrProvinceNotFound)
+	}
+
+	wards, err := Wards(1, "phuong tr")
+	if err != nil || len(wards) != 1 || wards[0].Code != 4 {
+		t.Errorf("Wards(1, \"phuong tr\") = %+v, %v; want only Trúc Bạch", wards, err)
+	}
+	if _, err := Wards(2, ""); !errors.Is(err, ErrDistrictNotFound) {
+		t.Errorf("Wards of an unknown district: got %v; want %v", err, ErrDistrictNotFound)
+	}
+}
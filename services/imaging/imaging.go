@@ -0,0 +1,217 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+)
+
+// MaxPixels caps the size of images that are decoded, so a small file that
+// expands to a huge bitmap can not exhaust memory. 16 MP covers phone
+// cameras and keeps a decoded image at about 64 MB in the worst case.
+const MaxPixels = 16_000_000
+
+var ErrUnsupportedFormat = errors.New("Only JPEG, PNG and GIF images are supported")
+var ErrTooLarge = errors.New("Image dimensions are too large")
+
+// Decode reads a JPEG, PNG or GIF image. The format is detected from the
+// content itself, not from what the client claims it is.
+func Decode(data []byte) (image.Image, error) {
+	switch http.DetectContentType(data) {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+	return img, nil
+}
+
+// SquareThumbnail crops the centre square out of src and scales it to
+// size x size by averaging the source pixels behind each output pixel.
+// Transparent areas are filled with white. The source is converted one row
+// at a time, so no second full-size bitmap is allocated.
+func SquareThumbnail(src image.Image, size int) *image.RGBA {
+	bounds := src.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	origin := image.Pt(bounds.Min.X+(bounds.Dx()-side)/2, bounds.Min.Y+(bounds.Dy()-side)/2)
+
+	row := image.NewRGBA(image.Rect(0, 0, side, 1))
+	white := make([]uint8, len(row.Pix))
+	for i := range white {
+		white[i] = 0xff
+	}
+	r := make([]int, size)
+	g := make([]int, size)
+	b := make([]int, size)
+	a := make([]int, size)
+	count := make([]int, size)
+
+	thumbnail := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			r[x], g[x], b[x], a[x], count[x] = 0, 0, 0, 0, 0
+		}
+
+		y0, y1 := span(y, size, side)
+		for sy := y0; sy < y1; sy++ {
+			copy(row.Pix, white)
+			draw.Draw(row, row.Bounds(), src, origin.Add(image.Pt(0, sy)), draw.Over)
+			for x := 0; x < size; x++ {
+				x0, x1 := span(x, size, side)
+				for sx := x0; sx < x1; sx++ {
+					pixel := row.Pix[sx*4 : sx*4+4]
+					r[x] += int(pixel[0])
+					g[x] += int(pixel[1])
+					b[x] += int(pixel[2])
+					a[x] += int(pixel[3])
+					count[x]++
+				}
+			}
+		}
+
+		for x := 0; x < size; x++ {
+			offset := y*thumbnail.Stride + x*4
+			thumbnail.Pix[offset] = uint8(r[x] / count[x])
+			thumbnail.Pix[offset+1] = uint8(g[x] / count[x])
+			thumbnail.Pix[offset+2] = uint8(b[x] / count[x])
+			thumbnail.Pix[offset+3] = uint8(a[x] / count[x])
+		}
+	}
+	return thumbnail
+}
+
+// Orientation returns the EXIF orientation of a JPEG, from 1 to 8, telling
+// how the camera held the picture. It returns 1, meaning upright, for other
+// formats and whenever the tag is missing or malformed.
+func Orientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data) && data[i] == 0xff; {
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == 0xda || length < 2 || i+2+length > len(data) {
+			break
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation reads the Orientation tag (0x0112) from the first IFD of
+// a TIFF structure.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < entries; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// Orient turns a square image upright according to an EXIF orientation.
+// Cropping the centre square and rotating commute, so it can be applied to
+// a thumbnail instead of to the full upload.
+func Orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	n := img.Bounds().Dx() - 1
+	oriented := image.NewRGBA(img.Bounds())
+	for y := 0; y <= n; y++ {
+		for x := 0; x <= n; x++ {
+			sx, sy := x, y
+			switch orientation {
+			case 2: // mirrored
+				sx = n - x
+			case 3: // upside down
+				sx, sy = n-x, n-y
+			case 4: // mirrored upside down
+				sy = n - y
+			case 5: // mirrored, rotated 90° counter-clockwise
+				sx, sy = y, x
+			case 6: // rotated 90° counter-clockwise
+				sx, sy = y, n-x
+			case 7: // mirrored, rotated 90° clockwise
+				sx, sy = n-y, n-x
+			case 8: // rotated 90° clockwise
+				sx, sy = n-y, x
+			}
+			copy(oriented.Pix[y*oriented.Stride+x*4:y*oriented.Stride+x*4+4], img.Pix[sy*img.Stride+sx*4:sy*img.Stride+sx*4+4])
+		}
+	}
+	return oriented
+}
+
+// EncodeJPEG re-encodes an image. Only pixels are written, so any metadata
+// of the original upload such as EXIF location data is left behind. Apply
+// Orient first, or the dropped orientation tag leaves the picture sideways.
+func EncodeJPEG(img image.Image) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := jpeg.Encode(&buffer, img, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// span returns the source range [start, end) behind output index i when
+// scaling length source pixels to size output pixels. It is never empty, so
+// images smaller than the thumbnail are scaled up.
+func span(i, size, length int) (int, int) {
+	start := i * length / size
+	end := (i + 1) * length / size
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
@@ -1,40 +1,154 @@
 package jwt
 
 import (
-	"database/sql"
-	"os"
+	"errors"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
+	sessions "github.com/quyld17/E-Commerce-Website/entities/session"
 	users "github.com/quyld17/E-Commerce-Website/entities/user"
+	"github.com/quyld17/E-Commerce-Website/services/token"
 )
 
-func Generate(email string, db *sql.DB) (string, error) {
-	err := godotenv.Load(".env")
+const AccessTokenTTL = 15 * time.Minute
+
+// Every token is signed with the same keys, so the audience is what tells
+// them apart. Access tokens are the only ones to accept as a credential;
+// services verifying through the JWKS endpoint must check for
+// AudienceAccess.
+const (
+	AudienceAccess       = "ecw:access"
+	audienceVerifyEmail  = "ecw:verify_email"
+	audienceMFAChallenge = "ecw:mfa_challenge"
+)
+
+// Generate issues a short-lived access token. The subject is the user ID,
+// which never changes, so tokens keep working if the account's email does.
+func Generate(user users.User, session sessions.Session) (string, error) {
+	tokenID, err := token.NewID()
 	if err != nil {
 		return "", err
 	}
 
-	role, err := users.GetRole(email, db)
+	claims := jwt.MapClaims{}
+	claims["jti"] = tokenID
+	claims["sub"] = strconv.Itoa(user.UserId)
+	claims["email"] = user.Email
+	claims["aud"] = AudienceAccess
+	claims["sid"] = session.SessionID
+	claims["mfa"] = session.MFAVerified
+	claims["iat"] = time.Now().Unix()
+	claims["exp"] = time.Now().Add(AccessTokenTTL).Unix()
+	claims["role"] = user.Role
+	tokenString, err := sign(claims)
 	if err != nil {
 		return "", err
 	}
 
-	token := jwt.New(jwt.SigningMethodHS256)
+	return tokenString, nil
+}
+
+const ImpersonationTTL = 10 * time.Minute
 
-	claims := token.Claims.(jwt.MapClaims)
-	claims["email"] = email
-	claims["exp"] = time.Now().Add(time.Hour * 72).Unix()
-	claims["role"] = role
-	secret := []byte(os.Getenv("JWT_SECRET_KEY"))
-	tokenString, err := token.SignedString(secret)
+// GenerateImpersonation issues an access token that lets staff see the shop
+// as customer does. The token is tied to the staff member's own session and
+// names them in the "act" claim, so it can always be told apart from one the
+// customer got by signing in. It returns the token ID and expiry so the
+// impersonation can be audited.
+func GenerateImpersonation(customer users.User, actorID int, actorSessionID string) (string, string, time.Time, error) {
+	tokenID, err := token.NewID()
 	if err != nil {
-		return "", err
+		return "", "", time.Time{}, err
 	}
+	expiresAt := time.Now().Add(ImpersonationTTL)
 
-	return tokenString, nil
+	claims := jwt.MapClaims{}
+	claims["jti"] = tokenID
+	claims["sub"] = strconv.Itoa(customer.UserId)
+	claims["email"] = customer.Email
+	claims["aud"] = AudienceAccess
+	claims["sid"] = actorSessionID
+	claims["mfa"] = false
+	claims["iat"] = time.Now().Unix()
+	claims["exp"] = expiresAt.Unix()
+	claims["role"] = customer.Role
+	claims["act"] = map[string]interface{}{"sub": strconv.Itoa(actorID)}
+	tokenString, err := sign(claims)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	return tokenString, tokenID, expiresAt, nil
+}
+
+const EmailVerificationTTL = 24 * time.Hour
+const MFAChallengeTTL = 5 * time.Minute
+
+var errInvalidVerification = errors.New("Invalid or expired verification link! Please request a new one")
+var errInvalidMFAChallenge = errors.New("Sign-in attempt has expired! Please sign in again")
+
+// GenerateEmailVerification signs a token that proves ownership of email for
+// the given account. It is bound to the email so a link sent to an old address
+// cannot verify a new one.
+func GenerateEmailVerification(userID int, email string) (string, error) {
+	return generatePurpose(audienceVerifyEmail, userID, email, EmailVerificationTTL)
+}
+
+func ParseEmailVerification(tokenString string) (int, string, error) {
+	userID, email, err := parsePurpose(audienceVerifyEmail, tokenString)
+	if err != nil {
+		return 0, "", errInvalidVerification
+	}
+	return userID, email, nil
+}
+
+// GenerateMFAChallenge signs the short-lived token handed out after the
+// password step of a two-step sign-in. It only proves the password was
+// correct and is exchanged for real tokens once the second factor checks out.
+func GenerateMFAChallenge(userID int, email string) (string, error) {
+	return generatePurpose(audienceMFAChallenge, userID, email, MFAChallengeTTL)
+}
+
+func ParseMFAChallenge(tokenString string) (int, string, error) {
+	userID, email, err := parsePurpose(audienceMFAChallenge, tokenString)
+	if err != nil {
+		return 0, "", errInvalidMFAChallenge
+	}
+	return userID, email, nil
+}
+
+// generatePurpose signs a single-purpose token. Its audience is the
+// purpose, so it is refused anywhere an access token is expected.
+func generatePurpose(audience string, userID int, email string, ttl time.Duration) (string, error) {
+	claims := jwt.MapClaims{}
+	claims["aud"] = audience
+	claims["user_id"] = userID
+	claims["email"] = email
+	claims["exp"] = time.Now().Add(ttl).Unix()
+	return sign(claims)
+}
+
+func parsePurpose(audience, tokenString string) (int, string, error) {
+	purposeToken, err := parse(tokenString, audience)
+	if err != nil || !purposeToken.Valid {
+		return 0, "", errors.New("invalid token")
+	}
+
+	claims, ok := purposeToken.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, "", errors.New("invalid token claims")
+	}
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, "", errors.New("invalid token claims")
+	}
+	email := GetClaims(purposeToken, "email")
+	if email == "" {
+		return 0, "", errors.New("invalid token claims")
+	}
+	return int(userID), email, nil
 }
 
 func GetToken(c echo.Context) string {
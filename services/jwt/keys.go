@@ -0,0 +1,211 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/quyld17/E-Commerce-Website/config"
+)
+
+// signingKey is one entry of the keyring. Asymmetric keys without a private
+// part are retired keys: they still verify tokens issued before a rotation
+// but are never used to sign.
+type signingKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+	Secret  []byte
+}
+
+type keyring struct {
+	active *signingKey
+	byID   map[string]*signingKey
+}
+
+var keys *keyring
+
+// LoadKeys builds the keyring once at startup.
+//
+// Asymmetric keys are read from the PEM files in KeysDir, one key per file
+// named <kid>.pem. Private keys (PKCS#8 RSA or Ed25519, or PKCS#1 RSA) can
+// sign and verify, public keys only verify. ActiveKeyID picks the key that
+// signs new tokens. When no asymmetric key is active, tokens are signed with
+// HS256 and SecretKey as before; the secret keeps verifying such tokens after
+// a switch until it is removed.
+func LoadKeys(cfg config.JWT) error {
+	ring := &keyring{byID: map[string]*signingKey{}}
+
+	if cfg.SecretKey != "" {
+		ring.byID[""] = &signingKey{Method: jwt.SigningMethodHS256, Secret: []byte(cfg.SecretKey)}
+	}
+
+	if cfg.KeysDir != "" {
+		paths, err := filepath.Glob(filepath.Join(cfg.KeysDir, "*.pem"))
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			key, err := readKey(path)
+			if err != nil {
+				return fmt.Errorf("loading JWT key %s: %w", path, err)
+			}
+			ring.byID[key.ID] = key
+		}
+	}
+
+	if cfg.ActiveKeyID != "" {
+		active, ok := ring.byID[cfg.ActiveKeyID]
+		if !ok || active.Private == nil {
+			return fmt.Errorf("JWT_ACTIVE_KEY_ID %q does not match a private key in JWT_KEYS_DIR", cfg.ActiveKeyID)
+		}
+		ring.active = active
+	} else if hmacKey, ok := ring.byID[""]; ok {
+		ring.active = hmacKey
+	} else {
+		return errors.New("no JWT signing key configured: set JWT_ACTIVE_KEY_ID or JWT_SECRET_KEY")
+	}
+
+	keys = ring
+	return nil
+}
+
+func readKey(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	key := &signingKey{ID: strings.TrimSuffix(filepath.Base(path), ".pem")}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case ed25519.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodEdDSA, k, k.Public()
+	case *rsa.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodRS256, k
+	case ed25519.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	return key, nil
+}
+
+func sign(claims jwt.MapClaims) (string, error) {
+	if keys == nil {
+		return "", errors.New("JWT keys are not loaded")
+	}
+	active := keys.active
+
+	token := jwt.NewWithClaims(active.Method, claims)
+	if active.Secret != nil {
+		return token.SignedString(active.Secret)
+	}
+	token.Header["kid"] = active.ID
+	return token.SignedString(active.Private)
+}
+
+// Parse verifies an access token against the key named by its kid header
+// and checks the algorithm matches that key, so an RSA public key can never
+// be used as an HMAC secret. Tokens for any other audience are refused.
+func Parse(tokenString string) (*jwt.Token, error) {
+	return parse(tokenString, AudienceAccess)
+}
+
+func parse(tokenString, audience string) (*jwt.Token, error) {
+	if keys == nil {
+		return nil, errors.New("JWT keys are not loaded")
+	}
+
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keys.byID[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		if key.Secret != nil {
+			return key.Secret, nil
+		}
+		return key.Public, nil
+	}, jwt.WithAudience(audience), jwt.WithValidMethods([]string{
+		jwt.SigningMethodHS256.Alg(),
+		jwt.SigningMethodRS256.Alg(),
+		jwt.SigningMethodEdDSA.Alg(),
+	}))
+}
+
+// JWKS returns the public half of every asymmetric key in the JSON Web Key
+// Set format, so other services can verify tokens without sharing a secret.
+func JWKS() map[string]interface{} {
+	set := []map[string]string{}
+	if keys == nil {
+		return map[string]interface{}{"keys": set}
+	}
+
+	ids := make([]string, 0, len(keys.byID))
+	for id := range keys.byID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		key := keys.byID[id]
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			set = append(set, map[string]string{
+				"kty": "RSA",
+				"kid": key.ID,
+				"alg": key.Method.Alg(),
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set = append(set, map[string]string{
+				"kty": "OKP",
+				"crv": "Ed25519",
+				"kid": key.ID,
+				"alg": key.Method.Alg(),
+				"use": "sig",
+				"x":   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	return map[string]interface{}{"keys": set}
+}
@@ -0,0 +1,97 @@
+package mailer
+
+import (
+	"fmt"
+	"io"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/quyld17/E-Commerce-Website/config"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(message Message) error
+}
+
+// New picks a mailer from the configured driver. SMTP is used in
+// production; the file and stdout drivers let the email flows run without a
+// mail server.
+func New(cfg config.Mailer) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		return &SMTPMailer{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.From,
+		}, nil
+	case "file":
+		return &FileMailer{Dir: cfg.Dir, From: cfg.From}, nil
+	case "", "stdout":
+		return &WriterMailer{Writer: os.Stdout, From: cfg.From}, nil
+	default:
+		return nil, fmt.Errorf("unknown MAILER_DRIVER %q", cfg.Driver)
+	}
+}
+
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(message Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{message.To}, format(m.From, message))
+}
+
+type WriterMailer struct {
+	Writer io.Writer
+	From   string
+}
+
+func (m *WriterMailer) Send(message Message) error {
+	_, err := m.Writer.Write(append(format(m.From, message), '\n'))
+	return err
+}
+
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (m *FileMailer) Send(message Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), strings.ReplaceAll(message.To, "@", "_at_"))
+	return os.WriteFile(filepath.Join(m.Dir, name), format(m.From, message), 0o644)
+}
+
+func format(from string, message Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + message.To + "\r\n")
+	b.WriteString("Subject: " + message.Subject + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(message.Body)
+	return []byte(b.String())
+}
@@ -0,0 +1,231 @@
+// Package shipping prices parcels. A destination falls in a zone depending on
+// how far it is from the warehouse, and every method has a rate table per
+// zone, priced either by parcel weight or by order value.
+package shipping
+
+import (
+	"errors"
+
+	"github.com/quyld17/E-Commerce-Website/services/geo"
+)
+
+const (
+	MethodStandard = "standard"
+	MethodExpress  = "express"
+)
+
+const (
+	ZoneInner    = "inner"    // same province as the warehouse
+	ZoneRegional = "regional" // same region of the country
+	ZoneNational = "national" // across regions
+)
+
+const (
+	BasisWeight = "weight" // brackets are in grams
+	BasisValue  = "value"  // brackets are in VND of order value
+)
+
+var ErrUnknownMethod = errors.New("Unknown shipping method")
+
+// Bracket charges Price for anything up to UpTo.
+type Bracket struct {
+	UpTo  int
+	Price int
+}
+
+// Rate is the table of one method in one zone. Above the last bracket every
+// started Step costs StepPrice more. Orders worth FreeOver or more ship for
+// free; 0 means never.
+type Rate struct {
+	Basis     string
+	Brackets  []Bracket
+	Step      int
+	StepPrice int
+	FreeOver  int
+	MinDays   int
+	MaxDays   int
+}
+
+type Method struct {
+	Code  string
+	Name  string
+	Rates map[string]Rate
+}
+
+// Parcel is what is being shipped, to where.
+type Parcel struct {
+	Province    string
+	WeightGrams int
+	OrderValue  int
+}
+
+// Option is a priced method offered for a parcel.
+type Option struct {
+	Method       string `json:"method"`
+	Name         string `json:"name"`
+	Zone         string `json:"zone"`
+	Fee          int    `json:"fee"`
+	FreeShipping bool   `json:"free_shipping"`
+	MinDays      int    `json:"min_days"`
+	MaxDays      int    `json:"max_days"`
+}
+
+// weightBrackets is the usual 0.5, 1 and 2 kg split of courier price lists.
+func weightBrackets(first, second, third int) []Bracket {
+	return []Bracket{{UpTo: 500, Price: first}, {UpTo: 1000, Price: second}, {UpTo: 2000, Price: third}}
+}
+
+// Methods lists what the shop offers, cheapest first. Prices are in VND.
+var Methods = []Method{
+	{
+		Code: MethodStandard,
+		Name: "Standard delivery",
+		Rates: map[string]Rate{
+			ZoneInner: {
+				Basis:    BasisValue,
+				Brackets: []Bracket{{UpTo: 99999, Price: 20000}, {UpTo: 299999, Price: 15000}},
+				FreeOver: 300000,
+				MinDays:  1,
+				MaxDays:  2,
+			},
+			ZoneRegional: {
+				Basis:     BasisWeight,
+				Brackets:  weightBrackets(22000, 27500, 33000),
+				Step:      500,
+				StepPrice: 5000,
+				FreeOver:  500000,
+				MinDays:   2,
+				MaxDays:   3,
+			},
+			ZoneNational: {
+				Basis:     BasisWeight,
+				Brackets:  weightBrackets(30000, 38000, 46000),
+				Step:      500,
+				StepPrice: 8000,
+				FreeOver:  1000000,
+				MinDays:   3,
+				MaxDays:   5,
+			},
+		},
+	},
+	{
+		Code: MethodExpress,
+		Name: "Express delivery",
+		Rates: map[string]Rate{
+			ZoneInner: {
+				Basis:     BasisWeight,
+				Brackets:  []Bracket{{UpTo: 2000, Price: 30000}},
+				Step:      500,
+				StepPrice: 5000,
+				MinDays:   0,
+				MaxDays:   1,
+			},
+			ZoneRegional: {
+				Basis:     BasisWeight,
+				Brackets:  weightBrackets(35000, 42000, 50000),
+				Step:      500,
+				StepPrice: 10000,
+				MinDays:   1,
+				MaxDays:   2,
+			},
+			ZoneNational: {
+				Basis:     BasisWeight,
+				Brackets:  weightBrackets(45000, 55000, 65000),
+				Step:      500,
+				StepPrice: 12000,
+				MinDays:   2,
+				MaxDays:   3,
+			},
+		},
+	},
+}
+
+// Zone places a destination province relative to the warehouse province.
+func Zone(originProvince int, destination geo.Province) string {
+	switch {
+	case destination.Code == originProvince:
+		return ZoneInner
+	case region(destination.Code) == region(originProvince):
+		return ZoneRegional
+	default:
+		return ZoneNational
+	}
+}
+
+// region splits the official province codes into the north (up to Ninh
+// Binh), the centre and highlands (Thanh Hoa to Lam Dong) and the south.
+func region(provinceCode int) int {
+	switch {
+	case provinceCode <= 37:
+		return 1
+	case provinceCode <= 68:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// Quote prices every method for a parcel sent from originProvince.
+func Quote(originProvince int, parcel Parcel) ([]Option, error) {
+	destination, ok := geo.FindProvince(parcel.Province)
+	if !ok {
+		return nil, geo.ErrProvinceNotFound
+	}
+	zone := Zone(originProvince, destination)
+
+	options := []Option{}
+	for _, method := range Methods {
+		rate, ok := method.Rates[zone]
+		if !ok {
+			continue
+		}
+		fee, free := rate.price(parcel)
+		options = append(options, Option{
+			Method:       method.Code,
+			Name:         method.Name,
+			Zone:         zone,
+			Fee:          fee,
+			FreeShipping: free,
+			MinDays:      rate.MinDays,
+			MaxDays:      rate.MaxDays,
+		})
+	}
+	return options, nil
+}
+
+// Choose returns the option for one method, priced the same way as Quote.
+func Choose(originProvince int, parcel Parcel, method string) (Option, error) {
+	options, err := Quote(originProvince, parcel)
+	if err != nil {
+		return Option{}, err
+	}
+	for _, option := range options {
+		if option.Method == method {
+			return option, nil
+		}
+	}
+	return Option{}, ErrUnknownMethod
+}
+
+func (r Rate) price(parcel Parcel) (int, bool) {
+	if r.FreeOver > 0 && parcel.OrderValue >= r.FreeOver {
+		return 0, true
+	}
+
+	amount := parcel.WeightGrams
+	if r.Basis == BasisValue {
+		amount = parcel.OrderValue
+	}
+	for _, bracket := range r.Brackets {
+		if amount <= bracket.UpTo {
+			return bracket.Price, false
+		}
+	}
+
+	last := r.Brackets[len(r.Brackets)-1]
+	if r.Step == 0 {
+		return last.Price, false
+	}
+	steps := (amount - last.UpTo + r.Step - 1) / r.Step
+	return last.Price + steps*r.StepPrice, false
+}
@@ -0,0 +1,130 @@
+package shipping
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/quyld17/E-Commerce-Website/services/geo"
+)
+
+func TestRatePrice(t *testing.T) {
+	standard := Methods[0].Rates
+	express := Methods[1].Rates
+	flat := Rate{Basis: BasisWeight, Brackets: []Bracket{{UpTo: 1000, Price: 10000}}}
+
+	tests := []struct {
+		name     string
+		rate     Rate
+		parcel   Parcel
+		wantFee  int
+		wantFree bool
+	}{
+		{"empty parcel uses the first bracket", standard[ZoneRegional], Parcel{WeightGrams: 0}, 22000, false},
+		{"first bracket edge", standard[ZoneRegional], Parcel{WeightGrams: 500}, 22000, false},
+		{"just over the first bracket", standard[ZoneRegional], Parcel{WeightGrams: 501}, 27500, false},
+		{"second bracket edge", standard[ZoneRegional], Parcel{WeightGrams: 1000}, 27500, false},
+		{"last bracket edge", standard[ZoneRegional], Parcel{WeightGrams: 2000}, 33000, false},
+		{"one gram over starts a step", standard[ZoneRegional], Parcel{WeightGrams: 2001}, 38000, false},
+		{"full step", standard[ZoneRegional], Parcel{WeightGrams: 2500}, 38000, false},
+		{"second step", standard[ZoneRegional], Parcel{WeightGrams: 2600}, 43000, false},
+		{"below the free threshold", standard[ZoneRegional], Parcel{WeightGrams: 2600, OrderValue: 499999}, 43000, false},
+		{"at the free threshold", standard[ZoneRegional], Parcel{WeightGrams: 2600, OrderValue: 500000}, 0, true},
+		{"national free threshold", standard[ZoneNational], Parcel{WeightGrams: 9000, OrderValue: 1000000}, 0, true},
+		{"value basis first bracket", standard[ZoneInner], Parcel{WeightGrams: 9000, OrderValue: 99999}, 20000, false},
+		{"value basis second bracket", standard[ZoneInner], Parcel{OrderValue: 100000}, 15000, false},
+		{"value basis below free", standard[ZoneInner], Parcel{OrderValue: 299999}, 15000, false},
+		{"value basis free", standard[ZoneInner], Parcel{OrderValue: 300000}, 0, true},
+		{"express is never free", express[ZoneInner], Parcel{WeightGrams: 2000, OrderValue: 10000000}, 30000, false},
+		{"express steps", express[ZoneInner], Parcel{WeightGrams: 3000}, 40000, false},
+		{"express regional steps", express[ZoneRegional], Parcel{WeightGrams: 2600}, 70000, false},
+		{"no step keeps the last price", flat, Parcel{WeightGrams: 5000}, 10000, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee, free := tt.rate.price(tt.parcel)
+			if fee != tt.wantFee || free != tt.wantFree {
+				t.Errorf("price(%+v) = %d, %v; want %d, %v", tt.parcel, fee, free, tt.wantFee, tt.wantFree)
+			}
+		})
+	}
+}
+
+func TestRegion(t *testing.T) {
+	tests := []struct {
+		provinceCode int
+		want         int
+	}{
+		{1, 1},
+		{37, 1},
+		{38, 2},
+		{68, 2},
+		{70, 3},
+		{79, 3},
+		{96, 3},
+	}
+	for _, tt := range tests {
+		if got := region(tt.provinceCode); got != tt.want {
+			t.Errorf("region(%d) = %d; want %d", tt.provinceCode, got, tt.want)
+		}
+	}
+}
+
+func TestZone(t *testing.T) {
+	tests := []struct {
+		origin      int
+		destination int
+		want        string
+	}{
+		{79, 79, ZoneInner},
+		{79, 74, ZoneRegional},
+		{79, 46, ZoneNational},
+		{79, 1, ZoneNational},
+		{1, 31, ZoneRegional},
+		{1, 37, ZoneRegional},
+		{1, 38, ZoneNational},
+	}
+	for _, tt := range tests {
+		if got := Zone(tt.origin, geo.Province{Code: tt.destination}); got != tt.want {
+			t.Errorf("Zone(%d, %d) = %q; want %q", tt.origin, tt.destination, got, tt.want)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	options, err := Quote(79, Parcel{Province: "Bình Dương", WeightGrams: 2600, OrderValue: 100000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{MethodStandard: 43000, MethodExpress: 70000}
+	if len(options) != len(want) {
+		t.Fatalf("got %d options; want %d", len(options), len(want))
+	}
+	for _, option := range options {
+		if option.Zone != ZoneRegional {
+			t.Errorf("%s zone = %q; want %q", option.Method, option.Zone, ZoneRegional)
+		}
+		if option.Fee != want[option.Method] {
+			t.Errorf("%s fee = %d; want %d", option.Method, option.Fee, want[option.Method])
+		}
+	}
+
+	if _, err := Quote(79, Parcel{Province: "Atlantis"}); !errors.Is(err, geo.ErrProvinceNotFound) {
+		t.Errorf("unknown province: got %v; want %v", err, geo.ErrProvinceNotFound)
+	}
+}
+
+func TestChoose(t *testing.T) {
+	parcel := Parcel{Province: "tp hcm", WeightGrams: 800, OrderValue: 350000}
+
+	option, err := Choose(79, parcel, MethodStandard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if option.Zone != ZoneInner || option.Fee != 0 || !option.FreeShipping {
+		t.Errorf("standard = %+v; want free inner delivery", option)
+	}
+
+	if _, err := Choose(79, parcel, "drone"); !errors.Is(err, ErrUnknownMethod) {
+		t.Errorf("unknown method: got %v; want %v", err, ErrUnknownMethod)
+	}
+}
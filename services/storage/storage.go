@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/quyld17/E-Commerce-Website/config"
+)
+
+// Storage keeps uploaded files under slash-separated keys such as
+// "avatars/12/abc-128.jpg" and knows the public URL of each.
+type Storage interface {
+	Put(key string, data []byte) error
+	Delete(key string) error
+	URL(key string) string
+}
+
+func New(cfg config.Storage) (Storage, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return &LocalStorage{Dir: cfg.Dir, BaseURL: cfg.BaseURL}, nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q", cfg.Driver)
+	}
+}
+
+// LocalStorage writes files to a directory on disk. The server exposes the
+// directory at BaseURL when it is a path rather than a full URL.
+type LocalStorage struct {
+	Dir     string
+	BaseURL string
+}
+
+func (s *LocalStorage) Put(key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see half a file.
+	temp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(temp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+func (s *LocalStorage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) URL(key string) string {
+	return s.BaseURL + "/" + key
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(s.Dir, cleaned), nil
+}
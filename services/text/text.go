@@ -0,0 +1,54 @@
+// Package text normalizes Vietnamese names for matching and for URLs.
+package text
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+var ErrInvalidSlug = errors.New("Invalid slug! Use lowercase letters, digits and dashes")
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+var stripMarks = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// Fold lowercases a name, drops Vietnamese diacritics and collapses
+// punctuation, so "Thừa Thiên-Huế" and "thua thien hue" compare equal.
+func Fold(name string) string {
+	name = strings.NewReplacer("đ", "d", "Đ", "d").Replace(name)
+	folded, _, err := transform.String(stripMarks, name)
+	if err != nil {
+		folded = name
+	}
+	folded = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, folded)
+	return strings.Join(strings.Fields(folded), " ")
+}
+
+// Slugify turns a name such as "Áo sơ mi nam" into "ao-so-mi-nam".
+func Slugify(name string) string {
+	return strings.ReplaceAll(Fold(name), " ", "-")
+}
+
+// Slug returns the slug to store for name: the one given, or one made from
+// the name when it is left empty.
+func Slug(name, slug string) (string, error) {
+	slug = strings.TrimSpace(slug)
+	if slug == "" {
+		slug = Slugify(name)
+	}
+	if len(slug) > 255 || !slugPattern.MatchString(slug) {
+		return "", ErrInvalidSlug
+	}
+	return slug, nil
+}
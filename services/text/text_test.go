@@ -0,0 +1,49 @@
+package text
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Thừa Thiên-Huế", "thua thien hue"},
+		{"thua thien hue", "thua thien hue"},
+		{"Đà Nẵng", "da nang"},
+		{"đường Đồng Khởi", "duong dong khoi"},
+		{"Bà Rịa – Vũng Tàu", "ba ria vung tau"},
+		{"  TP.  Hồ Chí Minh ", "tp ho chi minh"},
+		{"Quận 10", "quan 10"},
+		{"Ỹ Ở Ữ ẵ ậ", "y o u a a"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Fold(tt.name); got != tt.want {
+			t.Errorf("Fold(%q) = %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSlug(t *testing.T) {
+	tests := []struct {
+		name, slug string
+		want       string
+		wantErr    error
+	}{
+		{"Áo sơ mi nam", "", "ao-so-mi-nam", nil},
+		{"Đồ bộ & Pijama", "", "do-bo-pijama", nil},
+		{"Anything", " summer-2024 ", "summer-2024", nil},
+		{"Anything", "Summer", "", ErrInvalidSlug},
+		{"Anything", "summer--sale", "", ErrInvalidSlug},
+		{"!!!", "", "", ErrInvalidSlug},
+	}
+	for _, tt := range tests {
+		got, err := Slug(tt.name, tt.slug)
+		if got != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("Slug(%q, %q) = %q, %v; want %q, %v", tt.name, tt.slug, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
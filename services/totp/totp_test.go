@@ -0,0 +1,123 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// secret is the RFC 6238 SHA-1 test key "12345678901234567890" in base32.
+const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateMatchesRFC6238(t *testing.T) {
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The RFC lists 8 digit codes; these are their last 6 digits.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		if got := generate(key, tt.unix/Period); got != tt.want {
+			t.Errorf("generate at %d = %s; want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateWindow(t *testing.T) {
+	issued := time.Unix(1234567890, 0)
+	code := "005924"
+	step := issued.Unix() / Period
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		at     time.Time
+		want   bool
+	}{
+		{"same step", secret, code, issued, true},
+		{"one step later", secret, code, issued.Add(Period * time.Second), true},
+		{"one step earlier", secret, code, issued.Add(-Period * time.Second), true},
+		{"two steps later", secret, code, issued.Add(2 * Period * time.Second), false},
+		{"two steps earlier", secret, code, issued.Add(-2 * Period * time.Second), false},
+		{"surrounding spaces", secret, " " + code + " ", issued, true},
+		{"lowercase secret", strings.ToLower(secret), code, issued, true},
+		{"wrong code", secret, "005925", issued, false},
+		{"too short", secret, "05924", issued, false},
+		{"too long", secret, "0059240", issued, false},
+		{"invalid secret", "not base32!", code, issued, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Validate(tt.secret, tt.code, tt.at)
+			if ok != tt.want {
+				t.Fatalf("Validate ok = %v; want %v", ok, tt.want)
+			}
+			if ok && got != step {
+				t.Errorf("Validate step = %d; want %d", got, step)
+			}
+		})
+	}
+}
+
+// Callers refuse a code whose step is not after the last accepted one, so
+// Validate must report the step the code belongs to, not the current one.
+func TestValidateReportsStepForReplayCheck(t *testing.T) {
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	current := now.Unix() / Period
+
+	tests := []struct {
+		name string
+		step int64
+	}{
+		{"previous step", current - 1},
+		{"current step", current},
+		{"next step", current + 1},
+	}
+	lastAccepted := int64(0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := generate(key, tt.step)
+			got, ok := Validate(secret, code, now)
+			if !ok || got != tt.step {
+				t.Fatalf("Validate = %d, %v; want %d, true", got, ok, tt.step)
+			}
+			if got <= lastAccepted {
+				t.Fatalf("step %d is not after the last accepted step %d", got, lastAccepted)
+			}
+			lastAccepted = got
+
+			replayed, ok := Validate(secret, code, now)
+			if !ok || replayed > lastAccepted {
+				t.Errorf("replayed code got step %d; want at most %d so it is refused", replayed, lastAccepted)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	generated, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := encoding.DecodeString(generated)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %v", generated, err)
+	}
+	if len(key) != 20 {
+		t.Errorf("secret has %d bytes; want 20", len(key))
+	}
+}